@@ -0,0 +1,211 @@
+package id
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/renproject/surge"
+)
+
+// A CachedBlob is hash-addressable content that holds the binary
+// representation of some content as raw bytes. Unlike a Blob, it can be
+// unmarshaled, and its Hash is computed once and then cached until the
+// CachedBlob is mutated. It is safe for concurrent use.
+//
+// The Hash of a CachedBlob is the hash of the raw bytes, so it is the same as
+// the Hash of the Blob that wraps the original content. By default, the SHA2
+// 256-bit hashing function is used. The binary representation of a CachedBlob
+// is prefixed by the length of the raw bytes, so its Hash is not the hash of
+// its binary representation. To put the raw bytes into a store that verifies
+// Hashes, use a Chunk of the Bytes instead of the CachedBlob.
+type CachedBlob struct {
+	mu     sync.RWMutex
+	data   []byte
+	hasher Hasher
+	hash   Hash
+	hashed bool
+}
+
+// NewCachedBlob returns a CachedBlob that holds a copy of the raw bytes.
+func NewCachedBlob(data []byte) *CachedBlob {
+	return NewCachedBlobWithHasher(data, SHA256Hasher)
+}
+
+// NewCachedBlobWithHasher is the same as NewCachedBlob, but the CachedBlob will
+// be hashed using the given Hasher instead of SHA2 256-bit.
+func NewCachedBlobWithHasher(data []byte, hasher Hasher) *CachedBlob {
+	blob := &CachedBlob{hasher: hasher}
+	blob.Set(data)
+	return blob
+}
+
+// NewCachedBlobFromContent returns a CachedBlob that holds a snapshot of the
+// binary representation of the content. The content is marshaled once, when
+// the CachedBlob is created, so later changes to the content are not seen by
+// the CachedBlob. Use SetContent to replace the snapshot. An error is returned
+// if the content cannot be marshaled.
+func NewCachedBlobFromContent(content surge.Marshaler) (*CachedBlob, error) {
+	data, err := surge.ToBinary(content)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedBlob{data: data, hasher: SHA256Hasher}, nil
+}
+
+// Bytes returns a copy of the raw bytes held by the CachedBlob.
+func (blob *CachedBlob) Bytes() []byte {
+	blob.mu.RLock()
+	defer blob.mu.RUnlock()
+
+	data := make([]byte, len(blob.data))
+	copy(data, blob.data)
+	return data
+}
+
+// Set the raw bytes held by the CachedBlob to a copy of the data. This
+// invalidates the cached Hash.
+func (blob *CachedBlob) Set(data []byte) {
+	copied := make([]byte, len(data))
+	copy(copied, data)
+
+	blob.mu.Lock()
+	defer blob.mu.Unlock()
+
+	blob.data = copied
+	blob.hashed = false
+}
+
+// SetContent sets the raw bytes held by the CachedBlob to a snapshot of the
+// binary representation of the content. As with NewCachedBlobFromContent,
+// later changes to the content are not seen by the CachedBlob. This
+// invalidates the cached Hash. An error is returned if the content cannot be
+// marshaled, in which case the CachedBlob is unchanged.
+func (blob *CachedBlob) SetContent(content surge.Marshaler) error {
+	data, err := surge.ToBinary(content)
+	if err != nil {
+		return err
+	}
+
+	blob.mu.Lock()
+	defer blob.mu.Unlock()
+
+	blob.data = data
+	blob.hashed = false
+	return nil
+}
+
+// Content unmarshals the raw bytes held by the CachedBlob into the content.
+func (blob *CachedBlob) Content(content surge.Unmarshaler) error {
+	blob.mu.RLock()
+	defer blob.mu.RUnlock()
+
+	_, _, err := content.Unmarshal(blob.data, surge.MaxBytes)
+	return err
+}
+
+// Hash returns the hash of the raw bytes held by the CachedBlob. The Hash is
+// only computed the first time this method is called after the CachedBlob is
+// created or mutated. It never returns an error.
+func (blob *CachedBlob) Hash() (Hash, error) {
+	blob.mu.RLock()
+	if blob.hashed {
+		defer blob.mu.RUnlock()
+		return blob.hash, nil
+	}
+	blob.mu.RUnlock()
+
+	blob.mu.Lock()
+	defer blob.mu.Unlock()
+
+	// Another goroutine may have computed the Hash before the write lock was
+	// acquired.
+	if !blob.hashed {
+		hasher := blob.hasher
+		if hasher == nil {
+			hasher = SHA256Hasher
+		}
+		blob.hash = hasher.Sum(blob.data)
+		blob.hashed = true
+	}
+	return blob.hash, nil
+}
+
+// SizeHint returns the number of bytes required to represent the CachedBlob in
+// binary.
+func (blob *CachedBlob) SizeHint() int {
+	blob.mu.RLock()
+	defer blob.mu.RUnlock()
+
+	return surge.SizeHintU32 + len(blob.data)
+}
+
+// Marshal into binary. The raw bytes are prefixed by their length as a 32-bit
+// integer, so that a CachedBlob can be embedded in other binary
+// representations, and is not limited to 65535 bytes. The cached Hash is not
+// marshaled.
+func (blob *CachedBlob) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	blob.mu.RLock()
+	defer blob.mu.RUnlock()
+
+	buf, rem, err := surge.MarshalU32(uint32(len(blob.data)), buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if len(buf) < len(blob.data) || rem < len(blob.data) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	copy(buf, blob.data)
+	return buf[len(blob.data):], rem - len(blob.data), nil
+}
+
+// Unmarshal from binary. This invalidates the cached Hash.
+func (blob *CachedBlob) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	dataLen := uint32(0)
+	buf, rem, err := surge.UnmarshalU32(&dataLen, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if uint64(len(buf)) < uint64(dataLen) || uint64(rem) < uint64(dataLen) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	data := make([]byte, dataLen)
+	copy(data, buf)
+
+	blob.mu.Lock()
+	defer blob.mu.Unlock()
+
+	blob.data = data
+	blob.hashed = false
+	return buf[dataLen:], rem - int(dataLen), nil
+}
+
+// MarshalJSON implements the JSON marshaler interface for the CachedBlob type.
+// It is represented as an unpadded base64 string of its raw bytes.
+func (blob *CachedBlob) MarshalJSON() ([]byte, error) {
+	blob.mu.RLock()
+	defer blob.mu.RUnlock()
+
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(blob.data))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the CachedBlob
+// type. It assumes that it has been represented as an unpadded base64 string
+// of its raw bytes. This invalidates the cached Hash.
+func (blob *CachedBlob) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+
+	blob.mu.Lock()
+	defer blob.mu.Unlock()
+
+	blob.data = decoded
+	blob.hashed = false
+	return nil
+}
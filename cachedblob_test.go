@@ -0,0 +1,302 @@
+package id_test
+
+import (
+	"encoding/json"
+	"hash"
+	"sync"
+	"sync/atomic"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingHasher counts the number of times that it has hashed data.
+type countingHasher struct {
+	n int64
+}
+
+func (hasher *countingHasher) Sum(data []byte) id.Hash {
+	atomic.AddInt64(&hasher.n, 1)
+	return id.SHA256Hasher.Sum(data)
+}
+
+func (hasher *countingHasher) New() hash.Hash {
+	return id.SHA256Hasher.New()
+}
+
+func (hasher *countingHasher) Count() int64 {
+	return atomic.LoadInt64(&hasher.n)
+}
+
+// hashList unmarshals a slice of hashes using surge.
+type hashList []id.Hash
+
+func (list *hashList) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	return surge.Unmarshal((*[]id.Hash)(list), buf, rem)
+}
+
+// blobWithNonce marshals a cached blob followed by a nonce using surge.
+type blobWithNonce struct {
+	Blob  *id.CachedBlob
+	Nonce uint64
+}
+
+func (value blobWithNonce) SizeHint() int {
+	return value.Blob.SizeHint() + surge.SizeHintU64
+}
+
+func (value blobWithNonce) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := value.Blob.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.MarshalU64(value.Nonce, buf, rem)
+}
+
+func (value *blobWithNonce) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := value.Blob.Unmarshal(buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.UnmarshalU64(&value.Nonce, buf, rem)
+}
+
+var _ = Describe("Cached blobs", func() {
+	Context("when hashing a cached blob", func() {
+		It("should return the same hash as a blob", func() {
+			f := func(data []byte) bool {
+				blob, err := id.NewCachedBlobFromContent(bytesMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				hash, err := blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				expected, err := id.NewBlob(bytesMarshaler(data)).Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(expected))
+
+				hash, err = id.NewCachedBlob(data).Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(data)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not return the hash of its binary representation", func() {
+			f := func(data []byte) bool {
+				blob := id.NewCachedBlob(data)
+				marshaled, err := surge.ToBinary(blob)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(marshaled).To(HaveLen(surge.SizeHintU32 + len(data)))
+				Expect(marshaled[surge.SizeHintU32:]).To(Equal(blob.Bytes()))
+				hash, err := blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(blob.Bytes())))
+				Expect(hash).ToNot(Equal(id.NewHash(marshaled)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should only compute the hash once", func() {
+			f := func(data []byte) bool {
+				hasher := &countingHasher{}
+				blob := id.NewCachedBlobWithHasher(data, hasher)
+				for i := 0; i < 10; i++ {
+					hash, err := blob.Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(id.NewHash(data)))
+				}
+				Expect(hasher.Count()).To(Equal(int64(1)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should only compute the hash once when read concurrently", func() {
+			data := []byte("concurrent")
+			hasher := &countingHasher{}
+			blob := id.NewCachedBlobWithHasher(data, hasher)
+			wg := new(sync.WaitGroup)
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					hash, err := blob.Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(id.NewHash(data)))
+				}()
+			}
+			wg.Wait()
+			Expect(hasher.Count()).To(Equal(int64(1)))
+		})
+	})
+
+	Context("when mutating a cached blob", func() {
+		It("should invalidate the cached hash", func() {
+			f := func(data, other []byte) bool {
+				hasher := &countingHasher{}
+				blob := id.NewCachedBlobWithHasher(data, hasher)
+				blob.Hash()
+
+				blob.Set(other)
+				hash, err := blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(other)))
+				Expect(blob.Bytes()).To(Equal(append([]byte{}, other...)))
+
+				Expect(blob.SetContent(bytesMarshaler(data))).To(Succeed())
+				hash, err = blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				expected, err := id.NewBlob(bytesMarshaler(data)).Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(expected))
+
+				marshaled, err := surge.ToBinary(id.NewCachedBlob(other))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(surge.FromBinary(blob, marshaled)).To(Succeed())
+				hash, err = blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(other)))
+
+				marshaled, err = json.Marshal(id.NewCachedBlob(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(json.Unmarshal(marshaled, blob)).To(Succeed())
+				hash, err = blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(data)))
+
+				Expect(hasher.Count()).To(Equal(int64(5)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not be mutated by the caller", func() {
+			data := []byte{1, 2, 3}
+			blob := id.NewCachedBlob(data)
+			data[0] = 0
+			blob.Bytes()[1] = 0
+			Expect(blob.Bytes()).To(Equal([]byte{1, 2, 3}))
+		})
+
+		It("should be safe for concurrent use", func() {
+			blob := id.NewCachedBlob([]byte{})
+			wg := new(sync.WaitGroup)
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						if i%2 == 0 {
+							blob.Set([]byte{byte(i), byte(j)})
+							continue
+						}
+						data := blob.Bytes()
+						hash, err := blob.Hash()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(hash).ToNot(Equal(id.Hash{}))
+						Expect(len(data)).To(BeNumerically("<=", 2))
+					}
+				}(i)
+			}
+			wg.Wait()
+		})
+	})
+
+	Context("when unmarshaling the content of a cached blob", func() {
+		It("should equal the original content", func() {
+			f := func(data []byte) bool {
+				blob, err := id.NewCachedBlobFromContent(bytesMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				content := bytesMarshaler{}
+				Expect(blob.Content(&content)).To(Succeed())
+				Expect([]byte(content)).To(Equal(append([]byte{}, data...)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should allow the content to allocate more memory than its size", func() {
+			f := func(hashes []id.Hash) bool {
+				data, err := surge.ToBinary(hashes)
+				Expect(err).ToNot(HaveOccurred())
+				content := hashList{}
+				Expect(id.NewCachedBlob(data).Content(&content)).To(Succeed())
+				Expect([]id.Hash(content)).To(Equal(append([]id.Hash{}, hashes...)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling", func() {
+		It("should equal itself using binary", func() {
+			f := func(data []byte) bool {
+				blob := id.NewCachedBlob(data)
+				marshaled, err := surge.ToBinary(blob)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.CachedBlob{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(unmarshaled.Bytes()).To(Equal(blob.Bytes()))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal itself using JSON", func() {
+			f := func(data []byte) bool {
+				blob := id.NewCachedBlob(data)
+				marshaled, err := json.Marshal(blob)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.CachedBlob{}
+				Expect(json.Unmarshal(marshaled, &unmarshaled)).To(Succeed())
+				Expect(unmarshaled.Bytes()).To(Equal(blob.Bytes()))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal itself when followed by another field", func() {
+			f := func(data []byte, nonce uint64) bool {
+				value := blobWithNonce{Blob: id.NewCachedBlob(data), Nonce: nonce}
+				marshaled, err := surge.ToBinary(value)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(marshaled).To(HaveLen(value.SizeHint()))
+				unmarshaled := blobWithNonce{Blob: new(id.CachedBlob)}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(unmarshaled.Blob.Bytes()).To(Equal(value.Blob.Bytes()))
+				Expect(unmarshaled.Nonce).To(Equal(nonce))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the buffer is too small", func() {
+			f := func(data []byte) bool {
+				blob := id.NewCachedBlob(data)
+				marshaled, err := surge.ToBinary(blob)
+				Expect(err).ToNot(HaveOccurred())
+				for i := 0; i < len(marshaled); i++ {
+					_, _, err := blob.Marshal(make([]byte, i), surge.MaxBytes)
+					Expect(err).To(HaveOccurred())
+					_, _, err = blob.Marshal(make([]byte, len(marshaled)), i)
+					Expect(err).To(HaveOccurred())
+
+					unmarshaled := id.CachedBlob{}
+					_, _, err = unmarshaled.Unmarshal(marshaled[:i], surge.MaxBytes)
+					Expect(err).To(HaveOccurred())
+					_, _, err = unmarshaled.Unmarshal(marshaled, i)
+					Expect(err).To(HaveOccurred())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
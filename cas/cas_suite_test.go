@@ -0,0 +1,13 @@
+package cas_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCas(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Content-Addressed Storage Suite")
+}
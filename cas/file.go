@@ -0,0 +1,156 @@
+package cas
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/renproject/id"
+)
+
+// FileStore is a Store that keeps every piece of content in its own file. The
+// files are sharded into sub-directories by the first byte of their Hash, so
+// that no directory becomes too large:
+//
+//  <dir>/<hex(hash[:1])>/<hex(hash)>
+//
+// Files are written to a temporary file and then renamed, so a piece of
+// content is never partially visible. It is safe for concurrent use, including
+// by multiple processes that share the same directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that keeps content in the given directory.
+// The directory is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put the binary representation of the content, and return its Hash. If the
+// content already exists, it is not rewritten.
+func (store *FileStore) Put(content id.Content) (id.Hash, error) {
+	hash, data, err := marshalContent(content)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	path := store.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	shard := filepath.Dir(path)
+	if err := os.MkdirAll(shard, 0700); err != nil {
+		return id.Hash{}, err
+	}
+	f, err := ioutil.TempFile(shard, ".tmp-")
+	if err != nil {
+		return id.Hash{}, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return id.Hash{}, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return id.Hash{}, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return id.Hash{}, err
+	}
+	return hash, nil
+}
+
+// Get the binary representation of the content with the given Hash.
+func (store *FileStore) Get(hash id.Hash) ([]byte, error) {
+	data, err := ioutil.ReadFile(store.path(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := verify(hash, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Has returns true if there is content with the given Hash, otherwise it
+// returns false.
+func (store *FileStore) Has(hash id.Hash) (bool, error) {
+	if _, err := os.Stat(store.path(hash)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete the content with the given Hash.
+func (store *FileStore) Delete(hash id.Hash) error {
+	if err := os.Remove(store.path(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Iterate calls the function with the Hash of every piece of content, in
+// ascending order. Files that are not named after a Hash (for example,
+// temporary files) are ignored.
+func (store *FileStore) Iterate(f func(hash id.Hash) error) error {
+	shards, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(store.dir, shard.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, file := range files {
+			hash, ok := parseHash(file.Name())
+			if !ok || shard.Name() != file.Name()[:2] {
+				continue
+			}
+			if err := f(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// path returns the path of the file for the content with the given Hash.
+func (store *FileStore) path(hash id.Hash) string {
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(store.dir, name[:2], name)
+}
+
+// parseHash returns the Hash that the file name represents, and whether or not
+// the file name represents a Hash.
+func parseHash(name string) (id.Hash, bool) {
+	hash := id.Hash{}
+	if len(name) != 2*id.SizeHintHash {
+		return hash, false
+	}
+	decoded, err := hex.DecodeString(name)
+	if err != nil || hex.EncodeToString(decoded) != name {
+		return hash, false
+	}
+	copy(hash[:], decoded)
+	return hash, true
+}
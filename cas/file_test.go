@@ -0,0 +1,93 @@
+package cas_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/cas"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File stores", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cas")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("when putting content", func() {
+		It("should shard files by the first byte of their hash", func() {
+			store, err := cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			hash, err := store.Put(id.NewChunk([]byte("data")))
+			Expect(err).ToNot(HaveOccurred())
+			name := hex.EncodeToString(hash[:])
+			data, err := ioutil.ReadFile(filepath.Join(dir, name[:2], name))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("data")))
+		})
+
+		It("should be persisted across stores", func() {
+			store, err := cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			hash, err := store.Put(id.NewChunk([]byte("data")))
+			Expect(err).ToNot(HaveOccurred())
+
+			store, err = cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := store.Get(hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("data")))
+		})
+	})
+
+	Context("when getting corrupted content", func() {
+		It("should return an error", func() {
+			store, err := cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			hash, err := store.Put(id.NewChunk([]byte("data")))
+			Expect(err).ToNot(HaveOccurred())
+			name := hex.EncodeToString(hash[:])
+			Expect(ioutil.WriteFile(filepath.Join(dir, name[:2], name), []byte("corrupted"), 0600)).To(Succeed())
+
+			_, err = store.Get(hash)
+			errHashMismatch := cas.ErrHashMismatch{}
+			Expect(errors.As(err, &errHashMismatch)).To(BeTrue())
+			Expect(errHashMismatch.Expected).To(Equal(hash))
+			Expect(errHashMismatch.Got).To(Equal(id.NewHash([]byte("corrupted"))))
+		})
+	})
+
+	Context("when iterating", func() {
+		It("should ignore files that are not content", func() {
+			store, err := cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			hash, err := store.Put(id.NewChunk([]byte("data")))
+			Expect(err).ToNot(HaveOccurred())
+			name := hex.EncodeToString(hash[:])
+			Expect(ioutil.WriteFile(filepath.Join(dir, name[:2], ".tmp-123"), []byte{}, 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "file"), []byte{}, 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "00"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "00", name), []byte{}, 0600)).To(Succeed())
+
+			visited := []id.Hash{}
+			Expect(store.Iterate(func(hash id.Hash) error {
+				visited = append(visited, hash)
+				return nil
+			})).To(Succeed())
+			Expect(visited).To(Equal([]id.Hash{hash}))
+		})
+	})
+})
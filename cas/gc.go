@@ -0,0 +1,207 @@
+package cas
+
+import (
+	"sync"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// A Linker is Content that links to other Content by Hash. Content that is
+// linked to by reachable Content is also reachable, and is not collected by a
+// Collector.
+type Linker interface {
+	Links() []id.Hash
+}
+
+// A LinkUnmarshaler is a Linker that can be unmarshaled from its binary
+// representation.
+type LinkUnmarshaler interface {
+	surge.Unmarshaler
+	Linker
+}
+
+// A LinkDecoder returns the Hashes that the Content with the given binary
+// representation links to. Content that does not link to anything must return
+// no Hashes, not an error.
+type LinkDecoder func(data []byte) ([]id.Hash, error)
+
+// NewLinkDecoder returns a LinkDecoder for stores that only contain one type of
+// Linker. The binary representation is unmarshaled into the value returned by
+// newLinker, and then its Links are returned.
+func NewLinkDecoder(newLinker func() LinkUnmarshaler) LinkDecoder {
+	return func(data []byte) ([]id.Hash, error) {
+		linker := newLinker()
+		if _, _, err := linker.Unmarshal(data, surge.MaxBytes); err != nil {
+			return nil, err
+		}
+		return linker.Links(), nil
+	}
+}
+
+// A Collector is a mark-and-sweep garbage collector for a Store. Content is
+// kept if it is pinned, or if it is linked to (directly or indirectly) by
+// pinned Content. All other Content is deleted when Collect is called.
+//
+// Pins are counted, so Content that has been pinned n times must be unpinned n
+// times before it can be collected. Pins are kept in memory, and are not
+// persisted.
+//
+// Content must be put through the Collector, not directly into the Store, so
+// that it cannot be collected while it is being put. It is safe for concurrent
+// use.
+type Collector struct {
+	store  Store
+	decode LinkDecoder
+
+	// gcMu is held for writing during collection, and for reading while
+	// content is being put or pinned.
+	gcMu *sync.RWMutex
+
+	pinsMu *sync.Mutex
+	pins   map[id.Hash]uint64
+}
+
+// NewCollector returns a Collector for the Store, with no pins. The LinkDecoder
+// is used to find the links of reachable Content during collection.
+func NewCollector(store Store, decode LinkDecoder) *Collector {
+	return &Collector{
+		store:  store,
+		decode: decode,
+
+		gcMu: new(sync.RWMutex),
+
+		pinsMu: new(sync.Mutex),
+		pins:   map[id.Hash]uint64{},
+	}
+}
+
+// Put the Content into the Store, and return its Hash. The Content is not
+// pinned.
+func (collector *Collector) Put(content id.Content) (id.Hash, error) {
+	collector.gcMu.RLock()
+	defer collector.gcMu.RUnlock()
+
+	return collector.store.Put(content)
+}
+
+// PutAndPin puts the Content into the Store and pins it, so that it cannot be
+// collected between being put and being pinned.
+func (collector *Collector) PutAndPin(content id.Content) (id.Hash, error) {
+	collector.gcMu.RLock()
+	defer collector.gcMu.RUnlock()
+
+	hash, err := collector.store.Put(content)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	collector.pin(hash)
+	return hash, nil
+}
+
+// Pin the Content with the given Hash. The Content does not need to be in the
+// Store yet.
+func (collector *Collector) Pin(hash id.Hash) {
+	collector.gcMu.RLock()
+	defer collector.gcMu.RUnlock()
+
+	collector.pin(hash)
+}
+
+// Unpin the Content with the given Hash. It returns false if the Content was
+// not pinned, otherwise it returns true.
+func (collector *Collector) Unpin(hash id.Hash) bool {
+	collector.pinsMu.Lock()
+	defer collector.pinsMu.Unlock()
+
+	n, ok := collector.pins[hash]
+	if !ok {
+		return false
+	}
+	if n <= 1 {
+		delete(collector.pins, hash)
+	} else {
+		collector.pins[hash] = n - 1
+	}
+	return true
+}
+
+// IsPinned returns true if the Content with the given Hash is pinned,
+// otherwise it returns false.
+func (collector *Collector) IsPinned(hash id.Hash) bool {
+	collector.pinsMu.Lock()
+	defer collector.pinsMu.Unlock()
+
+	_, ok := collector.pins[hash]
+	return ok
+}
+
+// Pins returns the Hashes of all pinned Content, in ascending order.
+func (collector *Collector) Pins() []id.Hash {
+	collector.pinsMu.Lock()
+	defer collector.pinsMu.Unlock()
+
+	hashes := make([]id.Hash, 0, len(collector.pins))
+	for hash := range collector.pins {
+		hashes = append(hashes, hash)
+	}
+	sortHashes(hashes)
+	return hashes
+}
+
+// Collect deletes all Content that is not reachable from pinned Content, and
+// returns the Hashes of the deleted Content. Pinned Content that is not in
+// the Store is ignored, as are links to Content that is not in the Store. If
+// reachable Content cannot be read, or its links cannot be decoded, then
+// nothing is deleted and an error is returned.
+//
+// Putting and pinning Content blocks until collection is done.
+func (collector *Collector) Collect() ([]id.Hash, error) {
+	collector.gcMu.Lock()
+	defer collector.gcMu.Unlock()
+
+	// Mark all reachable content.
+	marked := map[id.Hash]struct{}{}
+	queue := collector.Pins()
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if _, ok := marked[hash]; ok {
+			continue
+		}
+		data, err := collector.store.Get(hash)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		marked[hash] = struct{}{}
+		links, err := collector.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, links...)
+	}
+
+	// Sweep all unreachable content.
+	swept := []id.Hash{}
+	err := collector.store.Iterate(func(hash id.Hash) error {
+		if _, ok := marked[hash]; ok {
+			return nil
+		}
+		if err := collector.store.Delete(hash); err != nil {
+			return err
+		}
+		swept = append(swept, hash)
+		return nil
+	})
+	return swept, err
+}
+
+func (collector *Collector) pin(hash id.Hash) {
+	collector.pinsMu.Lock()
+	defer collector.pinsMu.Unlock()
+
+	collector.pins[hash]++
+}
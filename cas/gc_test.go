@@ -0,0 +1,227 @@
+package cas_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/cas"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testNode is content that links to other content.
+type testNode struct {
+	Payload []byte
+	Edges   []id.Hash
+}
+
+func (node testNode) SizeHint() int {
+	return surge.SizeHint(node.Payload) + surge.SizeHint(node.Edges)
+}
+
+func (node testNode) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(node.Payload, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(node.Edges, buf, rem)
+}
+
+func (node *testNode) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&node.Payload, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Unmarshal(&node.Edges, buf, rem)
+}
+
+func (node testNode) Hash() (id.Hash, error) {
+	data, err := surge.ToBinary(node)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	return id.NewHash(data), nil
+}
+
+func (node testNode) Links() []id.Hash {
+	return node.Edges
+}
+
+var decodeTestNode = cas.NewLinkDecoder(func() cas.LinkUnmarshaler {
+	return &testNode{}
+})
+
+var _ = Describe("Garbage collection", func() {
+	// put a random DAG of nodes, and return their hashes in topological order
+	// (every node only links to nodes before it).
+	putDAG := func(collector *cas.Collector, r *rand.Rand, n int) []id.Hash {
+		hashes := []id.Hash{}
+		for i := 0; i < n; i++ {
+			node := testNode{Payload: []byte(fmt.Sprintf("%v-%v", i, r.Int()))}
+			for j := range hashes {
+				if r.Intn(4) == 0 {
+					node.Edges = append(node.Edges, hashes[j])
+				}
+			}
+			hash, err := collector.Put(node)
+			Expect(err).ToNot(HaveOccurred())
+			hashes = append(hashes, hash)
+		}
+		return hashes
+	}
+
+	// reachable returns the set of hashes that are reachable from the roots.
+	reachable := func(store cas.Store, roots []id.Hash) map[id.Hash]struct{} {
+		seen := map[id.Hash]struct{}{}
+		var visit func(hash id.Hash)
+		visit = func(hash id.Hash) {
+			if _, ok := seen[hash]; ok {
+				return
+			}
+			data, err := store.Get(hash)
+			if err != nil {
+				return
+			}
+			seen[hash] = struct{}{}
+			links, err := decodeTestNode(data)
+			Expect(err).ToNot(HaveOccurred())
+			for _, link := range links {
+				visit(link)
+			}
+		}
+		for _, root := range roots {
+			visit(root)
+		}
+		return seen
+	}
+
+	Context("when collecting garbage", func() {
+		It("should only keep content that is reachable from pins", func() {
+			for seed := int64(0); seed < 20; seed++ {
+				r := rand.New(rand.NewSource(seed))
+				store := cas.NewMemoryStore()
+				collector := cas.NewCollector(store, decodeTestNode)
+				hashes := putDAG(collector, r, 50)
+
+				pins := []id.Hash{}
+				for i := 0; i < 3; i++ {
+					pin := hashes[r.Intn(len(hashes))]
+					collector.Pin(pin)
+					pins = append(pins, pin)
+				}
+				expected := reachable(store, pins)
+
+				swept, err := collector.Collect()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(store.Len()).To(Equal(len(expected)))
+				Expect(len(swept)).To(Equal(len(hashes) - len(expected)))
+				for _, hash := range hashes {
+					ok, err := store.Has(hash)
+					Expect(err).ToNot(HaveOccurred())
+					_, isReachable := expected[hash]
+					Expect(ok).To(Equal(isReachable))
+				}
+			}
+		})
+
+		It("should collect everything when nothing is pinned", func() {
+			store := cas.NewMemoryStore()
+			collector := cas.NewCollector(store, decodeTestNode)
+			hashes := putDAG(collector, rand.New(rand.NewSource(0)), 20)
+			swept, err := collector.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swept).To(HaveLen(len(hashes)))
+			Expect(store.Len()).To(Equal(0))
+		})
+
+		It("should ignore pins and links to missing content", func() {
+			store := cas.NewMemoryStore()
+			collector := cas.NewCollector(store, decodeTestNode)
+			hash, err := collector.PutAndPin(testNode{Edges: []id.Hash{{1}}})
+			Expect(err).ToNot(HaveOccurred())
+			collector.Pin(id.Hash{2})
+			swept, err := collector.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swept).To(BeEmpty())
+			ok, err := store.Has(hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should not delete anything if links cannot be decoded", func() {
+			store := cas.NewMemoryStore()
+			collector := cas.NewCollector(store, func([]byte) ([]id.Hash, error) {
+				return nil, fmt.Errorf("undecodable")
+			})
+			hashes := putDAG(collector, rand.New(rand.NewSource(0)), 20)
+			collector.Pin(hashes[0])
+			_, err := collector.Collect()
+			Expect(err).To(MatchError("undecodable"))
+			Expect(store.Len()).To(Equal(len(hashes)))
+		})
+
+		It("should be safe to put and pin content concurrently", func() {
+			store := cas.NewMemoryStore()
+			collector := cas.NewCollector(store, decodeTestNode)
+			wg := new(sync.WaitGroup)
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						leaf := testNode{Payload: []byte(fmt.Sprintf("%v-%v", i, j))}
+						leafHash, err := leaf.Hash()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = collector.Put(leaf)
+						Expect(err).ToNot(HaveOccurred())
+						hash, err := collector.PutAndPin(testNode{Edges: []id.Hash{leafHash}})
+						Expect(err).ToNot(HaveOccurred())
+						_, err = collector.Collect()
+						Expect(err).ToNot(HaveOccurred())
+						ok, err := store.Has(hash)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(ok).To(BeTrue())
+					}
+				}(i)
+			}
+			wg.Wait()
+		})
+	})
+
+	Context("when pinning content", func() {
+		It("should count pins", func() {
+			collector := cas.NewCollector(cas.NewMemoryStore(), decodeTestNode)
+			hash := id.Hash{1}
+			Expect(collector.IsPinned(hash)).To(BeFalse())
+			collector.Pin(hash)
+			collector.Pin(hash)
+			Expect(collector.IsPinned(hash)).To(BeTrue())
+			Expect(collector.Pins()).To(Equal([]id.Hash{hash}))
+			Expect(collector.Unpin(hash)).To(BeTrue())
+			Expect(collector.IsPinned(hash)).To(BeTrue())
+			Expect(collector.Unpin(hash)).To(BeTrue())
+			Expect(collector.IsPinned(hash)).To(BeFalse())
+			Expect(collector.Unpin(hash)).To(BeFalse())
+			Expect(collector.Pins()).To(BeEmpty())
+		})
+
+		It("should collect content after it is unpinned", func() {
+			store := cas.NewMemoryStore()
+			collector := cas.NewCollector(store, decodeTestNode)
+			hash, err := collector.PutAndPin(testNode{Payload: []byte("data")})
+			Expect(err).ToNot(HaveOccurred())
+			swept, err := collector.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swept).To(BeEmpty())
+			collector.Unpin(hash)
+			swept, err = collector.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swept).To(Equal([]id.Hash{hash}))
+		})
+	})
+})
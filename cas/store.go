@@ -0,0 +1,190 @@
+// Package cas implements content-addressed storage. Content is stored under
+// the SHA2 256-bit hash of its binary representation, and every read is
+// verified by rehashing the stored bytes, so a Store never returns bytes that
+// do not match the requested Hash.
+package cas
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// ErrNotFound is returned by a Store when there is no content with the
+// requested Hash.
+var ErrNotFound = errors.New("content not found")
+
+// ErrHashMismatch is returned by a Store when bytes do not hash to the Hash
+// that they are expected to have. When putting content, this means that the
+// Hash of the content is not the hash of its binary representation. When
+// getting content, this means that the stored bytes have been corrupted.
+type ErrHashMismatch struct {
+	Expected id.Hash
+	Got      id.Hash
+}
+
+// Error implements the error interface.
+func (err ErrHashMismatch) Error() string {
+	return fmt.Sprintf("expected hash=%v, got hash=%v", err.Expected, err.Got)
+}
+
+// A Store persists content by its Hash. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Put the binary representation of the content, and return its Hash. The
+	// Hash of the content must be the SHA2 256-bit hash of its binary
+	// representation (as it is for an id.Blob or an id.Chunk), otherwise
+	// ErrHashMismatch must be returned. Putting content that already exists
+	// is not an error.
+	Put(content id.Content) (id.Hash, error)
+	// Get the binary representation of the content with the given Hash.
+	// ErrNotFound must be returned if there is no such content, and
+	// ErrHashMismatch must be returned if the stored bytes do not hash to the
+	// given Hash.
+	Get(hash id.Hash) ([]byte, error)
+	// Has returns true if there is content with the given Hash, otherwise it
+	// returns false.
+	Has(hash id.Hash) (bool, error)
+	// Delete the content with the given Hash. Deleting content that does not
+	// exist is not an error.
+	Delete(hash id.Hash) error
+	// Iterate calls the function with the Hash of every piece of content. If
+	// the function returns an error, then iteration stops and the error is
+	// returned. The function is allowed to modify the Store, but content that
+	// is put during iteration might not be visited.
+	Iterate(f func(hash id.Hash) error) error
+}
+
+// MemoryStore is a Store that keeps all content in memory. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	mu       *sync.RWMutex
+	contents map[id.Hash][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:       new(sync.RWMutex),
+		contents: map[id.Hash][]byte{},
+	}
+}
+
+// Put the binary representation of the content, and return its Hash.
+func (store *MemoryStore) Put(content id.Content) (id.Hash, error) {
+	hash, data, err := marshalContent(content)
+	if err != nil {
+		return id.Hash{}, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.contents[hash] = data
+	return hash, nil
+}
+
+// Get the binary representation of the content with the given Hash.
+func (store *MemoryStore) Get(hash id.Hash) ([]byte, error) {
+	store.mu.RLock()
+	data, ok := store.contents[hash]
+	store.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := verify(hash, data); err != nil {
+		return nil, err
+	}
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	return copied, nil
+}
+
+// Has returns true if there is content with the given Hash, otherwise it
+// returns false. It never returns an error.
+func (store *MemoryStore) Has(hash id.Hash) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	_, ok := store.contents[hash]
+	return ok, nil
+}
+
+// Delete the content with the given Hash.
+func (store *MemoryStore) Delete(hash id.Hash) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.contents, hash)
+	return nil
+}
+
+// Iterate calls the function with the Hash of every piece of content, in
+// ascending order.
+func (store *MemoryStore) Iterate(f func(hash id.Hash) error) error {
+	store.mu.RLock()
+	hashes := make([]id.Hash, 0, len(store.contents))
+	for hash := range store.contents {
+		hashes = append(hashes, hash)
+	}
+	store.mu.RUnlock()
+
+	sortHashes(hashes)
+	for _, hash := range hashes {
+		if err := f(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of pieces of content in the MemoryStore.
+func (store *MemoryStore) Len() int {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return len(store.contents)
+}
+
+// marshalContent returns the Hash and binary representation of the content,
+// and checks that the Hash of the content is the hash of its binary
+// representation.
+func marshalContent(content id.Content) (id.Hash, []byte, error) {
+	data, err := surge.ToBinary(content)
+	if err != nil {
+		return id.Hash{}, nil, err
+	}
+	hash, err := content.Hash()
+	if err != nil {
+		return id.Hash{}, nil, err
+	}
+	if err := verify(hash, data); err != nil {
+		return id.Hash{}, nil, err
+	}
+	return hash, data, nil
+}
+
+// verify that the data hashes to the Hash.
+func verify(hash id.Hash, data []byte) error {
+	got := id.NewHash(data)
+	if !got.Equal(&hash) {
+		return ErrHashMismatch{Expected: hash, Got: got}
+	}
+	return nil
+}
+
+func sortHashes(hashes []id.Hash) {
+	sort.Slice(hashes, func(i, j int) bool {
+		for k := range hashes[i] {
+			if hashes[i][k] != hashes[j][k] {
+				return hashes[i][k] < hashes[j][k]
+			}
+		}
+		return false
+	})
+}
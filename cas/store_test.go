@@ -0,0 +1,226 @@
+package cas_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/cas"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// misleadingContent claims a Hash that is not the hash of its binary
+// representation.
+type misleadingContent struct {
+	id.Chunk
+}
+
+func (misleadingContent) Hash() (id.Hash, error) {
+	return id.Hash{1}, nil
+}
+
+var _ = Describe("Stores", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cas")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	stores := []struct {
+		name     string
+		newStore func() cas.Store
+	}{
+		{"memory", func() cas.Store { return cas.NewMemoryStore() }},
+		{"file", func() cas.Store {
+			store, err := cas.NewFileStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			return store
+		}},
+	}
+
+	for _, s := range stores {
+		s := s
+
+		Context(fmt.Sprintf("when putting and getting content using a %v store", s.name), func() {
+			It("should return the bytes of the content", func() {
+				store := s.newStore()
+				f := func(data []byte) bool {
+					blob := id.NewChunk(data)
+					hash, err := store.Put(blob)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(id.NewHash(data)))
+
+					ok, err := store.Has(hash)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ok).To(BeTrue())
+
+					got, err := store.Get(hash)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(got).To(Equal(blob.Data()))
+
+					// Putting the same content again is not an error.
+					_, err = store.Put(blob)
+					Expect(err).ToNot(HaveOccurred())
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should return the bytes of a cached blob", func() {
+				store := s.newStore()
+				f := func(data []byte) bool {
+					blob := id.NewCachedBlob(data)
+					hash, err := store.Put(id.NewChunk(blob.Bytes()))
+					Expect(err).ToNot(HaveOccurred())
+					expected, err := blob.Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(expected))
+
+					got, err := store.Get(hash)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(got).To(Equal(blob.Bytes()))
+					fetched := id.CachedBlob{}
+					fetched.Set(got)
+					Expect(fetched.Hash()).To(Equal(hash))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should return an error for content that is not found", func() {
+				store := s.newStore()
+				f := func(hash id.Hash) bool {
+					_, err := store.Get(hash)
+					Expect(err).To(Equal(cas.ErrNotFound))
+					ok, err := store.Has(hash)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ok).To(BeFalse())
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should return an error for content with a mismatched hash", func() {
+				store := s.newStore()
+				_, err := store.Put(misleadingContent{id.NewChunk([]byte("data"))})
+				Expect(errors.As(err, &cas.ErrHashMismatch{})).To(BeTrue())
+				ok, err := store.Has(id.Hash{1})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context(fmt.Sprintf("when deleting content from a %v store", s.name), func() {
+			It("should not be found", func() {
+				store := s.newStore()
+				f := func(data []byte) bool {
+					hash, err := store.Put(id.NewChunk(data))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(store.Delete(hash)).To(Succeed())
+					_, err = store.Get(hash)
+					Expect(err).To(Equal(cas.ErrNotFound))
+					// Deleting content that does not exist is not an error.
+					Expect(store.Delete(hash)).To(Succeed())
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+		})
+
+		Context(fmt.Sprintf("when iterating over a %v store", s.name), func() {
+			It("should visit every hash in ascending order", func() {
+				store := s.newStore()
+				expected := map[id.Hash]struct{}{}
+				for i := 0; i < 100; i++ {
+					hash, err := store.Put(id.NewChunk([]byte(fmt.Sprintf("%v", i))))
+					Expect(err).ToNot(HaveOccurred())
+					expected[hash] = struct{}{}
+				}
+				visited := []id.Hash{}
+				Expect(store.Iterate(func(hash id.Hash) error {
+					visited = append(visited, hash)
+					return nil
+				})).To(Succeed())
+				Expect(visited).To(HaveLen(len(expected)))
+				for i := range visited {
+					Expect(expected).To(HaveKey(visited[i]))
+					if i > 0 {
+						Expect(bytes.Compare(visited[i-1][:], visited[i][:])).To(Equal(-1))
+					}
+				}
+			})
+
+			It("should allow content to be deleted during iteration", func() {
+				store := s.newStore()
+				for i := 0; i < 100; i++ {
+					_, err := store.Put(id.NewChunk([]byte(fmt.Sprintf("%v", i))))
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Expect(store.Iterate(store.Delete)).To(Succeed())
+				n := 0
+				Expect(store.Iterate(func(id.Hash) error {
+					n++
+					return nil
+				})).To(Succeed())
+				Expect(n).To(Equal(0))
+			})
+
+			It("should stop when the function returns an error", func() {
+				store := s.newStore()
+				for i := 0; i < 10; i++ {
+					_, err := store.Put(id.NewChunk([]byte(fmt.Sprintf("%v", i))))
+					Expect(err).ToNot(HaveOccurred())
+				}
+				n := 0
+				err := store.Iterate(func(id.Hash) error {
+					n++
+					return fmt.Errorf("stop")
+				})
+				Expect(err).To(MatchError("stop"))
+				Expect(n).To(Equal(1))
+			})
+		})
+
+		Context(fmt.Sprintf("when using a %v store concurrently", s.name), func() {
+			It("should be safe", func() {
+				store := s.newStore()
+				wg := new(sync.WaitGroup)
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer GinkgoRecover()
+						defer wg.Done()
+						for j := 0; j < 20; j++ {
+							data := []byte(fmt.Sprintf("%v", j))
+							hash, err := store.Put(id.NewChunk(data))
+							Expect(err).ToNot(HaveOccurred())
+							got, err := store.Get(hash)
+							if err == nil {
+								Expect(got).To(Equal(data))
+							} else {
+								Expect(err).To(Equal(cas.ErrNotFound))
+							}
+							if i%2 == 0 {
+								Expect(store.Delete(hash)).To(Succeed())
+							}
+						}
+					}(i)
+				}
+				wg.Wait()
+			})
+		})
+	}
+})
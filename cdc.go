@@ -0,0 +1,340 @@
+package id
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/renproject/surge"
+)
+
+// ChunkerOptions define the sizes of the chunks produced by a Chunker. The
+// average size must be a power of two.
+type ChunkerOptions struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// DefaultChunkerOptions returns the recommended ChunkerOptions, with chunks
+// that are between 2 KiB and 64 KiB, and are 8 KiB on average.
+func DefaultChunkerOptions() ChunkerOptions {
+	return ChunkerOptions{
+		MinSize: 2 << 10,
+		AvgSize: 8 << 10,
+		MaxSize: 64 << 10,
+	}
+}
+
+// A Chunk is a slice of data produced by a Chunker. It implements the Content
+// interface, and is marshaled as its raw bytes (without a length prefix), so
+// its Hash is the same as calling NewHash on its data.
+type Chunk struct {
+	data []byte
+	hash Hash
+}
+
+// NewChunk returns a Chunk that wraps the data. The data must not be modified
+// after the Chunk is created.
+func NewChunk(data []byte) Chunk {
+	return Chunk{data: data, hash: NewHash(data)}
+}
+
+// Data returns the data in the Chunk. It must not be modified.
+func (chunk Chunk) Data() []byte {
+	return chunk.data
+}
+
+// Hash returns the SHA2 256-bit hash of the data in the Chunk. It never returns
+// an error.
+func (chunk Chunk) Hash() (Hash, error) {
+	return chunk.hash, nil
+}
+
+// SizeHint returns the number of bytes required to represent the Chunk in
+// binary.
+func (chunk Chunk) SizeHint() int {
+	return len(chunk.data)
+}
+
+// Marshal into binary.
+func (chunk Chunk) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if len(buf) < len(chunk.data) || rem < len(chunk.data) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	copy(buf, chunk.data)
+	return buf[len(chunk.data):], rem - len(chunk.data), nil
+}
+
+// A Chunker splits data into content-defined chunks, using the FastCDC
+// algorithm. The boundaries of the chunks are chosen using a rolling hash of
+// the data, instead of being at fixed offsets, so inserting or removing bytes
+// only changes the chunks that are near to the modification. This allows
+// different versions of the same data to share most of their chunks.
+type Chunker struct {
+	r     io.Reader
+	opts  ChunkerOptions
+	maskS uint64
+	maskL uint64
+
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+// NewChunker returns a Chunker that reads data from the reader. An error is
+// returned if the ChunkerOptions are invalid.
+func NewChunker(r io.Reader, opts ChunkerOptions) (*Chunker, error) {
+	if opts.MinSize <= 0 || opts.MinSize > opts.AvgSize || opts.AvgSize > opts.MaxSize {
+		return nil, fmt.Errorf("expected 0<min<=avg<=max, got min=%v, avg=%v, max=%v", opts.MinSize, opts.AvgSize, opts.MaxSize)
+	}
+	if opts.AvgSize&(opts.AvgSize-1) != 0 {
+		return nil, fmt.Errorf("expected avg to be a power of two, got avg=%v", opts.AvgSize)
+	}
+	// Normalised chunking: before reaching the average size, a boundary is
+	// less likely (one more bit must be zero), and after reaching the average
+	// size, a boundary is more likely (one less bit must be zero). This
+	// narrows the distribution of chunk sizes around the average size.
+	n := bits.Len(uint(opts.AvgSize)) - 1
+	return &Chunker{
+		r:     r,
+		opts:  opts,
+		maskS: chunkerMask(n + 1),
+		maskL: chunkerMask(n - 1),
+		buf:   make([]byte, opts.MaxSize),
+	}, nil
+}
+
+// Next returns the next Chunk. When there are no more chunks, io.EOF is
+// returned. Any other error is returned by the reader.
+func (chunker *Chunker) Next() (Chunk, error) {
+	if !chunker.eof && chunker.end-chunker.start < chunker.opts.MaxSize {
+		copy(chunker.buf, chunker.buf[chunker.start:chunker.end])
+		chunker.end -= chunker.start
+		chunker.start = 0
+		n, err := io.ReadFull(chunker.r, chunker.buf[chunker.end:])
+		chunker.end += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			chunker.eof = true
+		} else if err != nil {
+			return Chunk{}, err
+		}
+	}
+	if chunker.start == chunker.end {
+		return Chunk{}, io.EOF
+	}
+	n := chunker.cut(chunker.buf[chunker.start:chunker.end])
+	data := make([]byte, n)
+	copy(data, chunker.buf[chunker.start:])
+	chunker.start += n
+	return NewChunk(data), nil
+}
+
+// cut returns the length of the next chunk at the front of the data.
+func (chunker *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= chunker.opts.MinSize {
+		return n
+	}
+	if n > chunker.opts.MaxSize {
+		n = chunker.opts.MaxSize
+	}
+	normal := chunker.opts.AvgSize
+	if n < normal {
+		normal = n
+	}
+	fp := uint64(0)
+	i := chunker.opts.MinSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + chunkerGear[data[i]]
+		if fp&chunker.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + chunkerGear[data[i]]
+		if fp&chunker.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// A ChunkManifestEntry identifies one Chunk in a ChunkManifest.
+type ChunkManifestEntry struct {
+	Hash Hash
+	Size uint32
+}
+
+// A ChunkManifest lists the Chunks that make up some data, in order. The data
+// is content-addressed by the root hash of the manifest, which is the merkle
+// hash of the chunk hashes. This means that a single Chunk can be verified
+// against the root hash using a MerkleProof (see VerifyChunk).
+type ChunkManifest struct {
+	Entries []ChunkManifestEntry
+}
+
+// NewChunkManifestFromReader splits all of the data from the reader into
+// content-defined Chunks (see Chunker), and returns the ChunkManifest for the
+// data. Every Chunk is passed to the callback as soon as it is produced, so
+// that it can be stored without holding all of the data in memory. The
+// callback can be nil. If the callback returns an error, then chunking stops
+// and the error is returned.
+func NewChunkManifestFromReader(r io.Reader, opts ChunkerOptions, f func(Chunk) error) (ChunkManifest, error) {
+	chunker, err := NewChunker(r, opts)
+	if err != nil {
+		return ChunkManifest{}, err
+	}
+	manifest := ChunkManifest{Entries: []ChunkManifestEntry{}}
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return manifest, nil
+		}
+		if err != nil {
+			return ChunkManifest{}, err
+		}
+		if f != nil {
+			if err := f(chunk); err != nil {
+				return ChunkManifest{}, err
+			}
+		}
+		manifest.Entries = append(manifest.Entries, ChunkManifestEntry{Hash: chunk.hash, Size: uint32(len(chunk.data))})
+	}
+}
+
+// Hashes returns the hashes of the Chunks in the ChunkManifest, in order.
+func (manifest ChunkManifest) Hashes() []Hash {
+	hashes := make([]Hash, len(manifest.Entries))
+	for i := range manifest.Entries {
+		hashes[i] = manifest.Entries[i].Hash
+	}
+	return hashes
+}
+
+// Size returns the total number of bytes in all of the Chunks in the
+// ChunkManifest.
+func (manifest ChunkManifest) Size() uint64 {
+	size := uint64(0)
+	for i := range manifest.Entries {
+		size += uint64(manifest.Entries[i].Size)
+	}
+	return size
+}
+
+// Root returns the merkle hash of the hashes of the Chunks in the
+// ChunkManifest.
+func (manifest ChunkManifest) Root() Hash {
+	return NewMerkleHashInPlace(manifest.Hashes())
+}
+
+// Equal compares one ChunkManifest with another. If they are equal, then it
+// returns true, otherwise it returns false.
+func (manifest ChunkManifest) Equal(other *ChunkManifest) bool {
+	if len(manifest.Entries) != len(other.Entries) {
+		return false
+	}
+	for i := range manifest.Entries {
+		if manifest.Entries[i] != other.Entries[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a ChunkManifest in
+// binary.
+func (manifest ChunkManifest) SizeHint() int {
+	return surge.SizeHintU64 + len(manifest.Entries)*(SizeHintHash+surge.SizeHintU32)
+}
+
+// Marshal into binary. The number of entries is marshaled as a 64-bit integer,
+// so that a ChunkManifest is not limited to 65535 Chunks.
+func (manifest ChunkManifest) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.MarshalU64(uint64(len(manifest.Entries)), buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	for i := range manifest.Entries {
+		if buf, rem, err = manifest.Entries[i].Hash.Marshal(buf, rem); err != nil {
+			return buf, rem, err
+		}
+		if buf, rem, err = surge.MarshalU32(manifest.Entries[i].Size, buf, rem); err != nil {
+			return buf, rem, err
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal from binary.
+func (manifest *ChunkManifest) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	n := uint64(0)
+	buf, rem, err := surge.UnmarshalU64(&n, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	// Check the memory quota before allocating anything.
+	if n > uint64(rem/(SizeHintHash+surge.SizeHintU32)) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	manifest.Entries = make([]ChunkManifestEntry, n)
+	for i := range manifest.Entries {
+		if buf, rem, err = manifest.Entries[i].Hash.Unmarshal(buf, rem); err != nil {
+			return buf, rem, err
+		}
+		if buf, rem, err = surge.UnmarshalU32(&manifest.Entries[i].Size, buf, rem); err != nil {
+			return buf, rem, err
+		}
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface for the ChunkManifest
+// type. It is represented as an unpadded base64 string of its binary
+// representation.
+func (manifest ChunkManifest) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the ChunkManifest
+// type. It assumes that it has been represented as an unpadded base64 string
+// of its binary representation.
+func (manifest *ChunkManifest) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(manifest, decoded)
+}
+
+// chunkerMask returns a mask with the n most significant bits set. The most
+// significant bits of the rolling hash depend on the most bytes, because the
+// rolling hash is shifted left after every byte.
+func chunkerMask(n int) uint64 {
+	return ^uint64(0) << uint(64-n)
+}
+
+// chunkerGear maps every byte to a pseudo-random 64-bit integer for the rolling
+// hash. The integers are derived from the SHA2 256-bit hash of the byte, so
+// that the boundaries of the chunks are stable across versions.
+var chunkerGear = func() [256]uint64 {
+	gear := [256]uint64{}
+	for i := range gear {
+		hash := sha256.Sum256([]byte{byte(i)})
+		gear[i] = binary.BigEndian.Uint64(hash[:8])
+	}
+	return gear
+}()
@@ -0,0 +1,224 @@
+package id_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing/iotest"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Content-defined chunks", func() {
+	opts := id.ChunkerOptions{MinSize: 64, AvgSize: 256, MaxSize: 1024}
+
+	randomData := func(r *rand.Rand, n int) []byte {
+		data := make([]byte, n)
+		r.Read(data)
+		return data
+	}
+
+	allChunks := func(data []byte) []id.Chunk {
+		chunker, err := id.NewChunker(iotest.HalfReader(bytes.NewReader(data)), opts)
+		Expect(err).ToNot(HaveOccurred())
+		chunks := []id.Chunk{}
+		for {
+			chunk, err := chunker.Next()
+			if err == io.EOF {
+				return chunks
+			}
+			Expect(err).ToNot(HaveOccurred())
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	Context("when chunking data", func() {
+		It("should return chunks that concatenate to the data", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				concatenated := []byte{}
+				for _, chunk := range allChunks(data) {
+					concatenated = append(concatenated, chunk.Data()...)
+				}
+				Expect(concatenated).To(Equal(data))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return chunks within the size bounds", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				chunks := allChunks(data)
+				for i, chunk := range chunks {
+					Expect(len(chunk.Data())).To(BeNumerically("<=", opts.MaxSize))
+					if i < len(chunks)-1 {
+						Expect(len(chunk.Data())).To(BeNumerically(">=", opts.MinSize))
+					}
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return chunks that are hashable content", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				for _, chunk := range allChunks(data) {
+					var content id.Content = chunk
+					hash, err := content.Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(id.NewHash(chunk.Data())))
+					blobHash, err := id.NewBlob(chunk).Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(blobHash).To(Equal(hash))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return mostly the same chunks after inserting data", func() {
+			r := rand.New(rand.NewSource(0))
+			data := randomData(r, 64*1024)
+			modified := append(randomData(r, 10), data...)
+			modified = append(modified[:32*1024], append(randomData(r, 10), modified[32*1024:]...)...)
+
+			hashes := map[id.Hash]struct{}{}
+			for _, chunk := range allChunks(data) {
+				hashes[id.NewHash(chunk.Data())] = struct{}{}
+			}
+			modifiedChunks := allChunks(modified)
+			numShared := 0
+			for _, chunk := range modifiedChunks {
+				if _, ok := hashes[id.NewHash(chunk.Data())]; ok {
+					numShared++
+				}
+			}
+			Expect(numShared).To(BeNumerically(">=", len(modifiedChunks)-6))
+		})
+
+		It("should return an error for invalid options", func() {
+			for _, opts := range []id.ChunkerOptions{
+				{MinSize: 0, AvgSize: 256, MaxSize: 1024},
+				{MinSize: 512, AvgSize: 256, MaxSize: 1024},
+				{MinSize: 64, AvgSize: 256, MaxSize: 128},
+				{MinSize: 64, AvgSize: 300, MaxSize: 1024},
+			} {
+				_, err := id.NewChunker(bytes.NewReader([]byte{}), opts)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+
+		It("should return an error if the reader returns an error", func() {
+			data := randomData(rand.New(rand.NewSource(0)), 4096)
+			chunker, err := id.NewChunker(iotest.TimeoutReader(bytes.NewReader(data)), opts)
+			Expect(err).ToNot(HaveOccurred())
+			for {
+				_, err := chunker.Next()
+				if err != nil {
+					Expect(err).To(Equal(iotest.ErrTimeout))
+					break
+				}
+			}
+		})
+	})
+
+	Context("when building a manifest", func() {
+		It("should return the chunks in order", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				stored := []id.Chunk{}
+				manifest, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, func(chunk id.Chunk) error {
+					stored = append(stored, chunk)
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(manifest.Entries).To(HaveLen(len(stored)))
+				Expect(manifest.Size()).To(Equal(uint64(len(data))))
+				for i := range stored {
+					Expect(manifest.Entries[i].Hash).To(Equal(id.NewHash(stored[i].Data())))
+					Expect(manifest.Entries[i].Size).To(Equal(uint32(len(stored[i].Data()))))
+				}
+				Expect(manifest.Root()).To(Equal(id.NewMerkleHash(manifest.Hashes())))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return chunks that can be verified against the root", func() {
+			data := randomData(rand.New(rand.NewSource(0)), 8192)
+			stored := []id.Chunk{}
+			manifest, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, func(chunk id.Chunk) error {
+				stored = append(stored, chunk)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			root := manifest.Root()
+			for i := range stored {
+				proof, err := id.NewMerkleProof(manifest.Hashes(), i)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyChunk(&root, len(stored), i, stored[i].Data(), &proof)).To(BeTrue())
+			}
+		})
+
+		It("should return an error if the callback returns an error", func() {
+			data := randomData(rand.New(rand.NewSource(0)), 4096)
+			_, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, func(chunk id.Chunk) error {
+				return fmt.Errorf("full")
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling a manifest", func() {
+		It("should equal itself using binary", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				manifest, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, nil)
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := surge.ToBinary(manifest)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.ChunkManifest{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(manifest.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal itself using JSON", func() {
+			f := func(seed int64, size uint16) bool {
+				data := randomData(rand.New(rand.NewSource(seed)), int(size)%8192)
+				manifest, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, nil)
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := json.Marshal(manifest)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.ChunkManifest{}
+				Expect(json.Unmarshal(marshaled, &unmarshaled)).To(Succeed())
+				Expect(manifest.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the buffer is too small", func() {
+			data := randomData(rand.New(rand.NewSource(0)), 4096)
+			manifest, err := id.NewChunkManifestFromReader(bytes.NewReader(data), opts, nil)
+			Expect(err).ToNot(HaveOccurred())
+			marshaled, err := surge.ToBinary(manifest)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < len(marshaled); i++ {
+				unmarshaled := id.ChunkManifest{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled[:i])).ToNot(Succeed())
+			}
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package id
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultChunkSize is the recommended number of bytes in a chunk when hashing
+// data in chunks.
+const DefaultChunkSize = 1 << 20
+
+// NewChunkedHashFromReader reads all of the data from the reader, and splits it
+// into chunks of the given size (the last chunk can be smaller). Every chunk is
+// hashed using NewHash, and the root hash of the merkle tree that uses the
+// chunk hashes as leaves is returned alongside the chunk hashes. Only one
+// chunk is held in memory at a time.
+//
+// The chunk hashes can be used with NewMerkleProof to produce the proof that
+// is needed to verify each chunk individually (see VerifyChunk). An error is
+// returned if the chunk size is not positive, or if the reader returns an
+// error other than io.EOF.
+func NewChunkedHashFromReader(r io.Reader, chunkSize int) (Hash, []Hash, error) {
+	if chunkSize <= 0 {
+		return Hash{}, nil, fmt.Errorf("expected chunk size>0, got chunk size=%v", chunkSize)
+	}
+	chunk := make([]byte, chunkSize)
+	hashes := []Hash{}
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			hashes = append(hashes, NewHash(chunk[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return Hash{}, nil, err
+		}
+	}
+	return NewMerkleHash(hashes), hashes, nil
+}
+
+// VerifyChunk returns true if the MerkleProof proves that the chunk is the
+// chunk at the given index, out of the given number of chunks, that was used
+// to produce the root hash returned by NewChunkedHashFromReader. Otherwise, it
+// returns false. The proof must have been produced by NewMerkleProof using the
+// chunk hashes.
+//
+// Unlike VerifyMerkleProof, this function also checks that the shape of the
+// proof matches the index, so that a chunk cannot be accepted at the wrong
+// position.
+func VerifyChunk(root *Hash, numChunks, index int, chunk []byte, proof *MerkleProof) bool {
+	if index < 0 || index >= numChunks {
+		return false
+	}
+	pathLen, bits := merkleProofShape(numChunks, index)
+	if len(proof.Path) != pathLen || proof.Bits != bits {
+		return false
+	}
+	leaf := NewHash(chunk)
+	return VerifyMerkleProof(root, &leaf, proof)
+}
+
+// merkleProofShape returns the length of the path, and the bits, of the
+// MerkleProof for the leaf at the given index of the merkle tree built by
+// NewMerkleHash with n leaves.
+func merkleProofShape(n, index int) (int, uint64) {
+	pathLen, bits := 0, uint64(0)
+	for l := n / 2; l >= 1; l = n / 2 {
+		b := n & 1
+		if b == 0 || index != 0 {
+			i := index - b
+			if i&1 == 1 {
+				bits |= 1 << uint(pathLen)
+			}
+			pathLen++
+			index = b + i/2
+		}
+		n = b + l
+	}
+	return pathLen, bits
+}
@@ -0,0 +1,114 @@
+package id_test
+
+import (
+	"bytes"
+	"testing/iotest"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chunks", func() {
+	splitChunks := func(data []byte, chunkSize int) [][]byte {
+		chunks := [][]byte{}
+		for len(data) > chunkSize {
+			chunks = append(chunks, data[:chunkSize])
+			data = data[chunkSize:]
+		}
+		if len(data) > 0 {
+			chunks = append(chunks, data)
+		}
+		return chunks
+	}
+
+	Context("when hashing a reader in chunks", func() {
+		It("should return the merkle hash of the chunk hashes", func() {
+			f := func(data []byte, size uint8) bool {
+				chunkSize := int(size%16) + 1
+				chunks := splitChunks(data, chunkSize)
+				root, hashes, err := id.NewChunkedHashFromReader(iotest.HalfReader(bytes.NewReader(data)), chunkSize)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hashes).To(HaveLen(len(chunks)))
+				for i := range chunks {
+					Expect(hashes[i]).To(Equal(id.NewHash(chunks[i])))
+				}
+				Expect(root).To(Equal(id.NewMerkleHash(hashes)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error if the chunk size is not positive", func() {
+			_, _, err := id.NewChunkedHashFromReader(bytes.NewReader([]byte{}), 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if the reader returns an error", func() {
+			r := iotest.TimeoutReader(bytes.NewReader([]byte{1, 2, 3}))
+			_, _, err := id.NewChunkedHashFromReader(r, 2)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when verifying chunks", func() {
+		It("should return true for every chunk", func() {
+			f := func(data []byte, size uint8) bool {
+				chunkSize := int(size%16) + 1
+				chunks := splitChunks(data, chunkSize)
+				root, hashes, err := id.NewChunkedHashFromReader(bytes.NewReader(data), chunkSize)
+				Expect(err).ToNot(HaveOccurred())
+				for i := range chunks {
+					proof, err := id.NewMerkleProof(hashes, i)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(id.VerifyChunk(&root, len(chunks), i, chunks[i], &proof)).To(BeTrue())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return false for modified chunks", func() {
+			f := func(data []byte, size uint8) bool {
+				chunkSize := int(size%16) + 1
+				chunks := splitChunks(data, chunkSize)
+				root, hashes, err := id.NewChunkedHashFromReader(bytes.NewReader(data), chunkSize)
+				Expect(err).ToNot(HaveOccurred())
+				for i := range chunks {
+					proof, err := id.NewMerkleProof(hashes, i)
+					Expect(err).ToNot(HaveOccurred())
+					modified := append([]byte{}, chunks[i]...)
+					modified[0]++
+					Expect(id.VerifyChunk(&root, len(chunks), i, modified, &proof)).To(BeFalse())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return false for chunks at the wrong index", func() {
+			f := func(data []byte, size uint8) bool {
+				chunkSize := int(size%16) + 1
+				chunks := splitChunks(data, chunkSize)
+				root, hashes, err := id.NewChunkedHashFromReader(bytes.NewReader(data), chunkSize)
+				Expect(err).ToNot(HaveOccurred())
+				for i := range chunks {
+					proof, err := id.NewMerkleProof(hashes, i)
+					Expect(err).ToNot(HaveOccurred())
+					for j := range chunks {
+						if i == j || hashes[i] == hashes[j] {
+							continue
+						}
+						Expect(id.VerifyChunk(&root, len(chunks), j, chunks[i], &proof)).To(BeFalse())
+					}
+					Expect(id.VerifyChunk(&root, len(chunks), -1, chunks[i], &proof)).To(BeFalse())
+					Expect(id.VerifyChunk(&root, len(chunks), len(chunks), chunks[i], &proof)).To(BeFalse())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
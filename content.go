@@ -1,6 +1,26 @@
 package id
 
-import "github.com/renproject/surge"
+import (
+	"fmt"
+	"sync"
+
+	"github.com/renproject/surge"
+)
+
+// maxPooledContentBufferSize is the maximum capacity of a buffer that will be
+// returned to the pool after hashing content. Larger buffers are left for the
+// garbage collector, so that hashing one large piece of content does not keep
+// a large buffer alive forever.
+const maxPooledContentBufferSize = 1 << 20
+
+// contentBufferPool stores the buffers used to marshal content before hashing
+// it.
+var contentBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
 
 // Content defines an interface for hash-addressable data. Content must be able
 // to represent itself in binary, and must expose a way to acquire a unique hash
@@ -16,9 +36,11 @@ type Content interface {
 }
 
 // A Blob is a helper type for hash-addressable content where the hash is known
-// to be the SHA2 256-bit hash of the binary representation of the content.
+// to be the hash of the binary representation of the content. By default, the
+// SHA2 256-bit hashing function is used.
 type Blob struct {
-	inner surge.Marshaler
+	inner  surge.Marshaler
+	hasher Hasher
 }
 
 // NewBlob returns a wrapper around a type that knows how to represent itself in
@@ -27,6 +49,12 @@ func NewBlob(blob surge.Marshaler) Blob {
 	return Blob{inner: blob}
 }
 
+// NewBlobWithHasher is the same as NewBlob, but the blob will be hashed using
+// the given Hasher instead of SHA2 256-bit.
+func NewBlobWithHasher(blob surge.Marshaler, hasher Hasher) Blob {
+	return Blob{inner: blob, hasher: hasher}
+}
+
 // SizeHint returns the number of bytes required to represent the blob in
 // binary. This returns the same number as the inner content used to create the
 // blob.
@@ -40,13 +68,96 @@ func (blob Blob) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	return blob.inner.Marshal(buf, rem)
 }
 
-// Hash returns the SHA2 256-bit hash of the inner content used to create the
-// blob.
+// Hash returns the hash of the inner content used to create the blob. Unless
+// the blob was created with a Hasher, this is the SHA2 256-bit hash.
 func (blob Blob) Hash() (Hash, error) {
-	buf := make([]byte, blob.SizeHint())
-	_, _, err := blob.Marshal(buf, surge.MaxBytes)
+	if blob.hasher == nil {
+		return HashContent(blob.inner)
+	}
+	return HashContentWithHasher(blob.inner, blob.hasher)
+}
+
+// HashContent returns the SHA2 256-bit hash of the binary representation of
+// the content. It is the same as marshaling the content and calling NewHash,
+// but the buffer used for marshaling is pooled, so hashing content does not
+// allocate memory in the common case.
+func HashContent(content surge.Marshaler) (Hash, error) {
+	return HashContentWithHasher(content, SHA256Hasher)
+}
+
+// HashContentWithHasher is the same as HashContent, but it uses the given
+// Hasher.
+func HashContentWithHasher(content surge.Marshaler, hasher Hasher) (Hash, error) {
+	sizeHint := content.SizeHint()
+	bufPtr := contentBufferPool.Get().(*[]byte)
+	if cap(*bufPtr) < sizeHint {
+		*bufPtr = make([]byte, sizeHint)
+	}
+	buf := (*bufPtr)[:sizeHint]
+	tail, _, err := content.Marshal(buf, surge.MaxBytes)
+	hash := Hash{}
+	if err == nil {
+		// Pooled buffers are not zeroed, so bytes that were not written by
+		// the content must be zeroed explicitly.
+		for i := range tail {
+			tail[i] = 0
+		}
+		hash = hasher.Sum(buf)
+	}
+	if cap(buf) <= maxPooledContentBufferSize {
+		contentBufferPool.Put(bufPtr)
+	}
+	return hash, err
+}
+
+// ErrHashingContent is returned when the Hash of one of a slice of Contents
+// cannot be computed. It identifies the index of the Content that failed.
+type ErrHashingContent struct {
+	Index int
+	Err   error
+}
+
+// Error implements the error interface.
+func (err ErrHashingContent) Error() string {
+	return fmt.Sprintf("hashing content=%v: %v", err.Index, err.Err)
+}
+
+// Unwrap returns the error returned by the Content.
+func (err ErrHashingContent) Unwrap() error {
+	return err.Err
+}
+
+// NewMerkleHashFromContents is the same as NewMerkleHash but it accepts a slice
+// of Contents, and uses their Hashes as the leaves. If the Hash of any Content
+// cannot be computed, then an ErrHashingContent is returned.
+func NewMerkleHashFromContents(contents []Content) (Hash, error) {
+	hashes, err := hashContents(contents)
 	if err != nil {
 		return Hash{}, err
 	}
-	return NewHash(buf), nil
+	return NewMerkleHashInPlace(hashes), nil
+}
+
+// NewMerkleHashAndProofsFromContents is the same as NewMerkleHashFromContents,
+// but it also returns the MerkleProof for every Content. The i-th MerkleProof
+// proves that the Hash of the i-th Content is a leaf of the merkle tree.
+func NewMerkleHashAndProofsFromContents(contents []Content) (Hash, []MerkleProof, error) {
+	hashes, err := hashContents(contents)
+	if err != nil {
+		return Hash{}, nil, err
+	}
+	root, proofs := newMerkleHashAndProofs(hashes)
+	return root, proofs, nil
+}
+
+func hashContents(contents []Content) ([]Hash, error) {
+	hashes := make([]Hash, len(contents))
+	for i := range contents {
+		hash, err := contents[i].Hash()
+		if err != nil {
+			return nil, ErrHashingContent{Index: i, Err: err}
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
 }
@@ -1 +1,196 @@
 package id_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// bytesMarshaler marshals a slice of bytes using surge.
+type bytesMarshaler []byte
+
+func (data bytesMarshaler) SizeHint() int {
+	return surge.SizeHintBytes(data)
+}
+
+func (data bytesMarshaler) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	return surge.MarshalBytes(data, buf, rem)
+}
+
+func (data *bytesMarshaler) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	return surge.UnmarshalBytes((*[]byte)(data), buf, rem)
+}
+
+// brokenContent is Content that cannot be hashed.
+type brokenContent struct {
+	bytesMarshaler
+}
+
+func (brokenContent) Hash() (id.Hash, error) {
+	return id.Hash{}, fmt.Errorf("broken")
+}
+
+// paddedMarshaler reports a size hint that is larger than the number of bytes
+// it marshals.
+type paddedMarshaler []byte
+
+func (data paddedMarshaler) SizeHint() int {
+	return len(data) + 8
+}
+
+func (data paddedMarshaler) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	copy(buf, data)
+	return buf[len(data):], rem - len(data), nil
+}
+
+// failingMarshaler cannot be marshaled.
+type failingMarshaler struct{}
+
+func (failingMarshaler) SizeHint() int {
+	return 8
+}
+
+func (failingMarshaler) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	return buf, rem, fmt.Errorf("failing")
+}
+
+var _ = Describe("Contents", func() {
+	Context("when hashing a blob", func() {
+		It("should return the hash of the binary representation", func() {
+			f := func(data []byte) bool {
+				blob := id.NewBlob(bytesMarshaler(data))
+				marshaled, err := surge.ToBinary(bytesMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				hash, err := blob.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(marshaled)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when hashing content", func() {
+		It("should return the hash of the binary representation", func() {
+			f := func(large, data []byte) bool {
+				// Hash large content first, so that a dirty buffer is
+				// returned to the pool.
+				_, err := id.HashContent(bytesMarshaler(append(large, data...)))
+				Expect(err).ToNot(HaveOccurred())
+
+				marshaled, err := surge.ToBinary(bytesMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				hash, err := id.HashContent(bytesMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(marshaled)))
+
+				hash, err = id.HashContentWithHasher(bytesMarshaler(data), id.Keccak256Hasher)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.Keccak256Hasher.Sum(marshaled)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should zero the bytes that are not marshaled", func() {
+			f := func(large, data []byte) bool {
+				_, err := id.HashContent(bytesMarshaler(append(large, data...)))
+				Expect(err).ToNot(HaveOccurred())
+
+				expected := make([]byte, len(data)+8)
+				copy(expected, data)
+				hash, err := id.HashContent(paddedMarshaler(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(expected)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error if the content cannot be marshaled", func() {
+			_, err := id.HashContent(failingMarshaler{})
+			Expect(err).To(MatchError("failing"))
+			_, err = id.NewBlob(failingMarshaler{}).Hash()
+			Expect(err).To(MatchError("failing"))
+		})
+	})
+
+	Context("when computing the merkle hash of contents", func() {
+		It("should equal the merkle hash of their hashes", func() {
+			f := func(data [][]byte) bool {
+				contents := make([]id.Content, len(data))
+				hashes := make([]id.Hash, len(data))
+				for i := range data {
+					contents[i] = id.NewBlob(bytesMarshaler(data[i]))
+					hash, err := contents[i].Hash()
+					Expect(err).ToNot(HaveOccurred())
+					hashes[i] = hash
+				}
+				root, err := id.NewMerkleHashFromContents(contents)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal(id.NewMerkleHash(hashes)))
+
+				root, proofs, err := id.NewMerkleHashAndProofsFromContents(contents)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal(id.NewMerkleHash(hashes)))
+				Expect(proofs).To(HaveLen(len(contents)))
+				for i := range proofs {
+					Expect(id.VerifyMerkleProof(&root, &hashes[i], &proofs[i])).To(BeTrue())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should identify the content that failed to hash", func() {
+			f := func(data [][]byte, index uint) bool {
+				contents := make([]id.Content, len(data)+1)
+				for i := range data {
+					contents[i] = id.NewBlob(bytesMarshaler(data[i]))
+				}
+				index = index % uint(len(contents))
+				contents[len(contents)-1] = contents[index]
+				contents[index] = brokenContent{}
+
+				_, err := id.NewMerkleHashFromContents(contents)
+				errHashingContent := id.ErrHashingContent{}
+				Expect(errors.As(err, &errHashingContent)).To(BeTrue())
+				Expect(errHashingContent.Index).To(Equal(int(index)))
+				Expect(errors.Unwrap(err)).To(MatchError("broken"))
+
+				_, _, err = id.NewMerkleHashAndProofsFromContents(contents)
+				Expect(errors.As(err, &errHashingContent)).To(BeTrue())
+				Expect(errHashingContent.Index).To(Equal(int(index)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
+
+func BenchmarkBlobHash(b *testing.B) {
+	blob := id.NewBlob(bytesMarshaler(make([]byte, 4096)))
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		blob.Hash()
+	}
+}
+
+func BenchmarkBlobHashUnpooled(b *testing.B) {
+	blob := id.NewBlob(bytesMarshaler(make([]byte, 4096)))
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := make([]byte, blob.SizeHint())
+		blob.Marshal(buf, surge.MaxBytes)
+		id.NewHash(buf)
+	}
+}
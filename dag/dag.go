@@ -0,0 +1,279 @@
+// Package dag implements merkle directed acyclic graphs (DAGs) of Content. A
+// node of the DAG is Content that links to other nodes by their Hash, so the
+// Hash of the root node commits to the entire DAG. Nodes can be fetched from
+// any untrusted source (for example, a peer), because every fetched node is
+// verified against the Hash that was used to fetch it.
+package dag
+
+import (
+	"errors"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/cas"
+	"github.com/renproject/surge"
+)
+
+// ErrSkipLinks can be returned by the function passed to a walk, to prevent
+// the walk from visiting the links of the current Node. It is not returned as
+// an error by the walk.
+var ErrSkipLinks = errors.New("skip links")
+
+// A Node is Content that links to other Nodes by their Hash.
+type Node interface {
+	id.Content
+	cas.Linker
+}
+
+// A NodeUnmarshaler is a Node that can be unmarshaled from its binary
+// representation.
+type NodeUnmarshaler interface {
+	Node
+	surge.Unmarshaler
+}
+
+// A Fetcher returns the binary representation of the Node with the given Hash.
+// It must return cas.ErrNotFound if the Node is not available. Every cas.Store
+// is a Fetcher.
+type Fetcher interface {
+	Get(hash id.Hash) ([]byte, error)
+}
+
+// FetcherFunc is a function that implements the Fetcher interface.
+type FetcherFunc func(hash id.Hash) ([]byte, error)
+
+// Get calls the function.
+func (f FetcherFunc) Get(hash id.Hash) ([]byte, error) {
+	return f(hash)
+}
+
+// A Decoder returns the Node with the given binary representation.
+type Decoder func(data []byte) (Node, error)
+
+// NewDecoder returns a Decoder for DAGs that only contain one type of Node. The
+// binary representation is unmarshaled into the value returned by newNode.
+func NewDecoder(newNode func() NodeUnmarshaler) Decoder {
+	return func(data []byte) (Node, error) {
+		node := newNode()
+		if _, _, err := node.Unmarshal(data, surge.MaxBytes); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
+
+// LinkDecoder returns a cas.LinkDecoder that decodes the Links of a Node, so
+// that DAGs can be garbage collected by a cas.Collector.
+func (decode Decoder) LinkDecoder() cas.LinkDecoder {
+	return func(data []byte) ([]id.Hash, error) {
+		node, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		return node.Links(), nil
+	}
+}
+
+// A Resolver resolves the Nodes of DAGs by fetching them from a Fetcher and
+// decoding them with a Decoder. It is safe for concurrent use if the Fetcher
+// and the Decoder are safe for concurrent use.
+type Resolver struct {
+	fetcher Fetcher
+	decode  Decoder
+}
+
+// NewResolver returns a Resolver that fetches and decodes Nodes using the given
+// Fetcher and Decoder.
+func NewResolver(fetcher Fetcher, decode Decoder) *Resolver {
+	return &Resolver{fetcher: fetcher, decode: decode}
+}
+
+// Resolve the Node with the given Hash. The fetched bytes must hash to the
+// given Hash, and so must the decoded Node, otherwise cas.ErrHashMismatch is
+// returned and the Node must not be trusted. Checking the bytes means that a
+// Decoder that ignores trailing bytes, or a Node that does not compute its
+// Hash from all of its fields, cannot be used to smuggle data into a DAG. If
+// the Node is not available, then cas.ErrNotFound is returned.
+func (resolver *Resolver) Resolve(hash id.Hash) (Node, error) {
+	data, err := resolver.fetcher.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if got := id.NewHash(data); !got.Equal(&hash) {
+		return nil, cas.ErrHashMismatch{Expected: hash, Got: got}
+	}
+	node, err := resolver.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	got, err := node.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if !got.Equal(&hash) {
+		return nil, cas.ErrHashMismatch{Expected: hash, Got: got}
+	}
+	return node, nil
+}
+
+// WalkBFS resolves every Node in the DAG with the given root Hash in
+// breadth-first order, and calls the function with each Node. Nodes that are
+// linked to more than once are only visited once. If the function returns
+// ErrSkipLinks, then the links of the Node are not visited. If the function
+// returns any other error, or a Node cannot be resolved, then the walk stops
+// and the error is returned.
+func (resolver *Resolver) WalkBFS(root id.Hash, f func(hash id.Hash, node Node) error) error {
+	visited := map[id.Hash]struct{}{root: {}}
+	queue := []id.Hash{root}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		node, err := resolver.Resolve(hash)
+		if err != nil {
+			return err
+		}
+		if err := f(hash, node); err != nil {
+			if err == ErrSkipLinks {
+				continue
+			}
+			return err
+		}
+		for _, link := range node.Links() {
+			if _, ok := visited[link]; ok {
+				continue
+			}
+			visited[link] = struct{}{}
+			queue = append(queue, link)
+		}
+	}
+	return nil
+}
+
+// WalkDFS is the same as WalkBFS, but the Nodes are visited in depth-first
+// pre-order. The links of a Node are visited in the order that they are
+// returned by the Node.
+func (resolver *Resolver) WalkDFS(root id.Hash, f func(hash id.Hash, node Node) error) error {
+	visited := map[id.Hash]struct{}{}
+	stack := []id.Hash{root}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := visited[hash]; ok {
+			continue
+		}
+		visited[hash] = struct{}{}
+		node, err := resolver.Resolve(hash)
+		if err != nil {
+			return err
+		}
+		if err := f(hash, node); err != nil {
+			if err == ErrSkipLinks {
+				continue
+			}
+			return err
+		}
+		links := node.Links()
+		for i := len(links) - 1; i >= 0; i-- {
+			if _, ok := visited[links[i]]; !ok {
+				stack = append(stack, links[i])
+			}
+		}
+	}
+	return nil
+}
+
+// Missing returns the Hashes of the Nodes in the DAG with the given root Hash
+// that are not available from the Fetcher, in breadth-first order. Only the
+// missing Nodes that are linked to by available Nodes can be found, so after
+// the missing Nodes have been fetched, Missing must be called again until no
+// Nodes are missing. An error is returned if an available Node cannot be
+// resolved (for example, because it does not match its Hash).
+func (resolver *Resolver) Missing(root id.Hash) ([]id.Hash, error) {
+	missing := []id.Hash{}
+	visited := map[id.Hash]struct{}{root: {}}
+	queue := []id.Hash{root}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		node, err := resolver.Resolve(hash)
+		if err == cas.ErrNotFound {
+			missing = append(missing, hash)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range node.Links() {
+			if _, ok := visited[link]; ok {
+				continue
+			}
+			visited[link] = struct{}{}
+			queue = append(queue, link)
+		}
+	}
+	return missing, nil
+}
+
+// Sync copies every Node in the DAG with the given root Hash into the Store.
+// Nodes are put into the Store after all of the Nodes that they link to, so
+// Nodes that are already in the Store are known to be complete: they are not
+// fetched, and their links are not visited. This means that an interrupted
+// Sync can be resumed by calling Sync again. Every fetched Node is verified
+// before it is put into the Store. An error is returned if a Node cannot be
+// resolved.
+func (resolver *Resolver) Sync(root id.Hash, store cas.Store) error {
+	// The DAG is traversed using an explicit stack, instead of recursion, so
+	// that long chains of Nodes do not grow the goroutine stack. Each frame
+	// holds a Node that has been resolved, but not yet put, and the index of
+	// its next link to visit.
+	type frame struct {
+		node  Node
+		links []id.Hash
+		next  int
+	}
+	stack := []frame{}
+	visited := map[id.Hash]struct{}{}
+
+	// visit pushes the Node with the given Hash onto the stack, unless it has
+	// already been visited or it is already in the Store.
+	visit := func(hash id.Hash) error {
+		if _, ok := visited[hash]; ok {
+			return nil
+		}
+		visited[hash] = struct{}{}
+
+		ok, err := store.Has(hash)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		node, err := resolver.Resolve(hash)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, frame{node: node, links: node.Links()})
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return err
+	}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(top.links) {
+			link := top.links[top.next]
+			top.next++
+			if err := visit(link); err != nil {
+				return err
+			}
+			continue
+		}
+		// Every link of the Node is in the Store, so the Node can be put.
+		if _, err := store.Put(top.node); err != nil {
+			return err
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return nil
+}
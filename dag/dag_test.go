@@ -0,0 +1,375 @@
+package dag_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/cas"
+	"github.com/renproject/id/dag"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testNode is a node with a payload and links to other nodes.
+type testNode struct {
+	Payload []byte
+	Edges   []id.Hash
+}
+
+func (node testNode) SizeHint() int {
+	return surge.SizeHint(node.Payload) + surge.SizeHint(node.Edges)
+}
+
+func (node testNode) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(node.Payload, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(node.Edges, buf, rem)
+}
+
+func (node *testNode) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&node.Payload, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Unmarshal(&node.Edges, buf, rem)
+}
+
+func (node testNode) Hash() (id.Hash, error) {
+	data, err := surge.ToBinary(node)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	return id.NewHash(data), nil
+}
+
+func (node testNode) Links() []id.Hash {
+	return node.Edges
+}
+
+// lyingNode is a node that claims a hash that is not the hash of its binary
+// representation.
+type lyingNode struct {
+	testNode
+	hash id.Hash
+}
+
+func (node lyingNode) Hash() (id.Hash, error) {
+	return node.hash, nil
+}
+
+// interruptedStore is a store that returns an error after n puts.
+type interruptedStore struct {
+	cas.Store
+	n int
+}
+
+func (store *interruptedStore) Put(content id.Content) (id.Hash, error) {
+	if store.n <= 0 {
+		return id.Hash{}, fmt.Errorf("interrupted")
+	}
+	store.n--
+	return store.Store.Put(content)
+}
+
+var decodeTestNode = dag.NewDecoder(func() dag.NodeUnmarshaler {
+	return &testNode{}
+})
+
+var _ = Describe("Merkle DAGs", func() {
+	put := func(store cas.Store, payload string, links ...id.Hash) id.Hash {
+		hash, err := store.Put(testNode{Payload: []byte(payload), Edges: links})
+		Expect(err).ToNot(HaveOccurred())
+		return hash
+	}
+
+	hashOf := func(payload string, links ...id.Hash) id.Hash {
+		hash, err := testNode{Payload: []byte(payload), Edges: links}.Hash()
+		Expect(err).ToNot(HaveOccurred())
+		return hash
+	}
+
+	payload := func(node dag.Node) string {
+		return string(node.(*testNode).Payload)
+	}
+
+	// putTree puts the DAG:
+	//
+	//      root
+	//     /    \
+	//    a      b
+	//   / \    / \
+	//  c   d  e   d
+	//
+	putTree := func(store cas.Store) id.Hash {
+		c := put(store, "c")
+		d := put(store, "d")
+		e := put(store, "e")
+		a := put(store, "a", c, d)
+		b := put(store, "b", e, d)
+		return put(store, "root", a, b)
+	}
+
+	// putRandomDAG puts a random DAG where the last node is the root, and
+	// every node is reachable from the root.
+	putRandomDAG := func(store cas.Store, r *rand.Rand, n int) id.Hash {
+		hashes := []id.Hash{}
+		unlinked := map[id.Hash]struct{}{}
+		for i := 0; i < n; i++ {
+			links := []id.Hash{}
+			for j := range hashes {
+				if _, ok := unlinked[hashes[j]]; ok || r.Intn(8) == 0 {
+					links = append(links, hashes[j])
+					delete(unlinked, hashes[j])
+				}
+			}
+			hash := put(store, fmt.Sprintf("%v-%v", i, r.Int()), links...)
+			hashes = append(hashes, hash)
+			unlinked[hash] = struct{}{}
+		}
+		return hashes[len(hashes)-1]
+	}
+
+	Context("when resolving a node", func() {
+		It("should return the node", func() {
+			store := cas.NewMemoryStore()
+			hash := put(store, "data")
+			node, err := dag.NewResolver(store, decodeTestNode).Resolve(hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payload(node)).To(Equal("data"))
+		})
+
+		It("should return an error if the node is not found", func() {
+			_, err := dag.NewResolver(cas.NewMemoryStore(), decodeTestNode).Resolve(id.Hash{})
+			Expect(err).To(Equal(cas.ErrNotFound))
+		})
+
+		It("should return an error if the node does not match its hash", func() {
+			store := cas.NewMemoryStore()
+			hash := put(store, "data")
+			other := put(store, "other")
+			fetcher := dag.FetcherFunc(func(id.Hash) ([]byte, error) {
+				return store.Get(other)
+			})
+			_, err := dag.NewResolver(fetcher, decodeTestNode).Resolve(hash)
+			errHashMismatch := cas.ErrHashMismatch{}
+			Expect(errors.As(err, &errHashMismatch)).To(BeTrue())
+			Expect(errHashMismatch.Expected).To(Equal(hash))
+			Expect(errHashMismatch.Got).To(Equal(other))
+		})
+
+		It("should return an error if the fetched bytes do not match the hash", func() {
+			store := cas.NewMemoryStore()
+			hash := put(store, "data")
+			data, err := store.Get(hash)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The decoder ignores trailing bytes, so the decoded node matches
+			// the hash, but the fetched bytes do not.
+			padded := append(append([]byte{}, data...), 0xff)
+			fetcher := dag.FetcherFunc(func(id.Hash) ([]byte, error) {
+				return padded, nil
+			})
+			node, err := decodeTestNode(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node.Hash()).To(Equal(hash))
+			_, err = dag.NewResolver(fetcher, decodeTestNode).Resolve(hash)
+			errHashMismatch := cas.ErrHashMismatch{}
+			Expect(errors.As(err, &errHashMismatch)).To(BeTrue())
+			Expect(errHashMismatch.Got).To(Equal(id.NewHash(padded)))
+
+			// The decoded node claims the requested hash, but the fetched
+			// bytes do not match it.
+			other := put(store, "other")
+			fetcher = dag.FetcherFunc(func(id.Hash) ([]byte, error) {
+				return store.Get(other)
+			})
+			decodeLyingNode := func(data []byte) (dag.Node, error) {
+				return lyingNode{hash: hash}, nil
+			}
+			_, err = dag.NewResolver(fetcher, decodeLyingNode).Resolve(hash)
+			Expect(errors.As(err, &errHashMismatch)).To(BeTrue())
+			Expect(errHashMismatch.Got).To(Equal(other))
+		})
+	})
+
+	Context("when walking a DAG", func() {
+		It("should visit every node once in breadth-first order", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			visited := []string{}
+			Expect(dag.NewResolver(store, decodeTestNode).WalkBFS(root, func(hash id.Hash, node dag.Node) error {
+				visited = append(visited, payload(node))
+				return nil
+			})).To(Succeed())
+			Expect(visited).To(Equal([]string{"root", "a", "b", "c", "d", "e"}))
+		})
+
+		It("should visit every node once in depth-first order", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			visited := []string{}
+			Expect(dag.NewResolver(store, decodeTestNode).WalkDFS(root, func(hash id.Hash, node dag.Node) error {
+				visited = append(visited, payload(node))
+				return nil
+			})).To(Succeed())
+			Expect(visited).To(Equal([]string{"root", "a", "c", "d", "b", "e"}))
+		})
+
+		It("should skip the links of a node", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			resolver := dag.NewResolver(store, decodeTestNode)
+			skipA := func(visited *[]string) func(id.Hash, dag.Node) error {
+				return func(hash id.Hash, node dag.Node) error {
+					*visited = append(*visited, payload(node))
+					if payload(node) == "a" {
+						return dag.ErrSkipLinks
+					}
+					return nil
+				}
+			}
+			visited := []string{}
+			Expect(resolver.WalkBFS(root, skipA(&visited))).To(Succeed())
+			Expect(visited).To(Equal([]string{"root", "a", "b", "e", "d"}))
+			visited = []string{}
+			Expect(resolver.WalkDFS(root, skipA(&visited))).To(Succeed())
+			Expect(visited).To(Equal([]string{"root", "a", "b", "e", "d"}))
+		})
+
+		It("should visit every reachable node of random DAGs", func() {
+			for seed := int64(0); seed < 10; seed++ {
+				store := cas.NewMemoryStore()
+				root := putRandomDAG(store, rand.New(rand.NewSource(seed)), 50)
+				resolver := dag.NewResolver(store, decodeTestNode)
+				for _, walk := range []func(id.Hash, func(id.Hash, dag.Node) error) error{resolver.WalkBFS, resolver.WalkDFS} {
+					visited := map[id.Hash]int{}
+					Expect(walk(root, func(hash id.Hash, node dag.Node) error {
+						visited[hash]++
+						return nil
+					})).To(Succeed())
+					Expect(visited).To(HaveLen(50))
+					for _, n := range visited {
+						Expect(n).To(Equal(1))
+					}
+				}
+			}
+		})
+
+		It("should stop when a node cannot be resolved", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			Expect(store.Delete(hashOf("c"))).To(Succeed())
+			resolver := dag.NewResolver(store, decodeTestNode)
+			noop := func(id.Hash, dag.Node) error { return nil }
+			Expect(resolver.WalkBFS(root, noop)).To(Equal(cas.ErrNotFound))
+			Expect(resolver.WalkDFS(root, noop)).To(Equal(cas.ErrNotFound))
+		})
+
+		It("should stop when the function returns an error", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			resolver := dag.NewResolver(store, decodeTestNode)
+			n := 0
+			stop := func(id.Hash, dag.Node) error {
+				n++
+				return fmt.Errorf("stop")
+			}
+			Expect(resolver.WalkBFS(root, stop)).To(MatchError("stop"))
+			Expect(resolver.WalkDFS(root, stop)).To(MatchError("stop"))
+			Expect(n).To(Equal(2))
+		})
+	})
+
+	Context("when finding missing nodes", func() {
+		It("should return the missing nodes that are linked to by available nodes", func() {
+			store := cas.NewMemoryStore()
+			root := putTree(store)
+			resolver := dag.NewResolver(store, decodeTestNode)
+			missing, err := resolver.Missing(root)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(BeEmpty())
+
+			c := hashOf("c")
+			d := hashOf("d")
+			a := hashOf("a", c, d)
+			Expect(store.Delete(a)).To(Succeed())
+			Expect(store.Delete(d)).To(Succeed())
+
+			// The children of a cannot be found until a is available, and d is
+			// found through b.
+			missing, err = resolver.Missing(root)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]id.Hash{a, d}))
+
+			missing, err = resolver.Missing(id.Hash{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]id.Hash{{}}))
+		})
+	})
+
+	Context("when syncing a DAG", func() {
+		It("should copy every node into the store", func() {
+			for seed := int64(0); seed < 10; seed++ {
+				remote := cas.NewMemoryStore()
+				root := putRandomDAG(remote, rand.New(rand.NewSource(seed)), 50)
+				local := cas.NewMemoryStore()
+				Expect(dag.NewResolver(remote, decodeTestNode).Sync(root, local)).To(Succeed())
+				Expect(local.Len()).To(Equal(50))
+				missing, err := dag.NewResolver(local, decodeTestNode).Missing(root)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(missing).To(BeEmpty())
+			}
+		})
+
+		It("should copy long chains of nodes", func() {
+			remote := cas.NewMemoryStore()
+			root := put(remote, "0")
+			for i := 1; i < 10000; i++ {
+				root = put(remote, fmt.Sprintf("%v", i), root)
+			}
+			local := cas.NewMemoryStore()
+			Expect(dag.NewResolver(remote, decodeTestNode).Sync(root, local)).To(Succeed())
+			Expect(local.Len()).To(Equal(10000))
+		})
+
+		It("should resume an interrupted sync", func() {
+			for seed := int64(0); seed < 10; seed++ {
+				remote := cas.NewMemoryStore()
+				root := putRandomDAG(remote, rand.New(rand.NewSource(seed)), 50)
+				local := cas.NewMemoryStore()
+				for i := 0; ; i++ {
+					err := dag.NewResolver(remote, decodeTestNode).Sync(root, &interruptedStore{Store: local, n: 10})
+					if err == nil {
+						break
+					}
+					Expect(err).To(MatchError("interrupted"))
+					Expect(i).To(BeNumerically("<", 5))
+				}
+				Expect(local.Len()).To(Equal(50))
+				missing, err := dag.NewResolver(local, decodeTestNode).Missing(root)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(missing).To(BeEmpty())
+			}
+		})
+	})
+
+	Context("when collecting garbage", func() {
+		It("should keep every node that is reachable from a pinned root", func() {
+			store := cas.NewMemoryStore()
+			root := putRandomDAG(store, rand.New(rand.NewSource(0)), 50)
+			putRandomDAG(store, rand.New(rand.NewSource(1)), 50)
+			collector := cas.NewCollector(store, decodeTestNode.LinkDecoder())
+			collector.Pin(root)
+			swept, err := collector.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swept).To(HaveLen(50))
+			Expect(store.Len()).To(Equal(50))
+		})
+	})
+})
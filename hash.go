@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"runtime"
+	"sync"
 	"unsafe"
 
 	"github.com/renproject/surge"
@@ -183,6 +185,79 @@ func NewMerkleHashInPlaceSafe(hashes []Hash) Hash {
 	return NewMerkleHashInPlaceSafe(hashes[:b+len(hashes)/2])
 }
 
+// minParallelMerkleLevel is the minimum number of pairs that must be hashed at
+// a level of the merkle tree before the work is split across goroutines. Below
+// this, the overhead of synchronising the goroutines outweighs the gains.
+const minParallelMerkleLevel = 1024
+
+// NewMerkleHashParallel is the same as NewMerkleHash, but it splits the hashing
+// at each level of the merkle tree across the given number of goroutines. If
+// the number of goroutines is not positive, then GOMAXPROCS goroutines are
+// used. The input slice is unmodified.
+func NewMerkleHashParallel(hashes []Hash, numWorkers int) Hash {
+	dst := make([]Hash, len(hashes))
+	copy(dst, hashes)
+	return NewMerkleHashInPlaceParallel(dst, numWorkers)
+}
+
+// NewMerkleHashInPlaceParallel is the same as NewMerkleHashParallel but it
+// overrides values in the input slice for efficiency. Only use this function if
+// you do not need the input slices.
+func NewMerkleHashInPlaceParallel(hashes []Hash, numWorkers int) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+	if numWorkers <= 0 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+
+	// Goroutines cannot hash a level in-place, because one goroutine would
+	// overwrite hashes that are still being read by another goroutine. So,
+	// levels are hashed back and forth between the input slice and a scratch
+	// slice.
+	var scratch []Hash
+	src := hashes
+	for l := len(src) / 2; l >= 1; l = len(src) / 2 {
+		b := len(src) & 1
+		if numWorkers == 1 || l < minParallelMerkleLevel {
+			for i := 0; i < l; i++ {
+				buf := (*[64]byte)(unsafe.Pointer(&src[b+i*2]))
+				src[b+i] = Hash(sha256.Sum256(buf[:]))
+			}
+			src = src[:b+l]
+			continue
+		}
+
+		if scratch == nil {
+			scratch = make([]Hash, b+l)
+		}
+		dst := scratch[:b+l]
+		if b == 1 {
+			dst[0] = src[0]
+		}
+		chunk := (l + numWorkers - 1) / numWorkers
+		wg := new(sync.WaitGroup)
+		for begin := 0; begin < l; begin += chunk {
+			end := begin + chunk
+			if end > l {
+				end = l
+			}
+			wg.Add(1)
+			go func(begin, end int) {
+				defer wg.Done()
+				for i := begin; i < end; i++ {
+					buf := (*[64]byte)(unsafe.Pointer(&src[b+i*2]))
+					dst[b+i] = Hash(sha256.Sum256(buf[:]))
+				}
+			}(begin, end)
+		}
+		wg.Wait()
+		scratch = src
+		src = dst
+	}
+	return src[0]
+}
+
 // NewMerkleHashFromSignatories is the same as NewMerkleHash but it accepts a
 // slice of Signatories instead of a slice of Hashes.
 func NewMerkleHashFromSignatories(signatories []Signatory) Hash {
@@ -191,6 +266,16 @@ func NewMerkleHashFromSignatories(signatories []Signatory) Hash {
 	return NewMerkleHashFromSignatoriesInPlace(dst)
 }
 
+// NewMerkleHashFromSignatoriesParallel is the same as NewMerkleHashParallel
+// but it accepts a slice of Signatories instead of a slice of Hashes.
+func NewMerkleHashFromSignatoriesParallel(signatories []Signatory, numWorkers int) Hash {
+	dst := make([]Hash, len(signatories))
+	for i := range signatories {
+		dst[i] = Hash(signatories[i])
+	}
+	return NewMerkleHashInPlaceParallel(dst, numWorkers)
+}
+
 // NewMerkleHashFromSignatoriesInPlace is the same as NewMerkleHashInPlace but
 // it accepts a slice of Signatories instead of a slice of Hashes.
 func NewMerkleHashFromSignatoriesInPlace(signatories []Signatory) Hash {
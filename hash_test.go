@@ -186,6 +186,54 @@ var _ = Describe("Hashes", func() {
 			})
 		})
 
+		Context("when using the parallel implementation", func() {
+			It("should return the same merkle hash", func() {
+				f := func(n uint, numWorkers int8) bool {
+					n = n % 10000
+					hashes := make([]id.Hash, n)
+					for i := range hashes {
+						rand.Read(hashes[i][:])
+					}
+					copied := make([]id.Hash, n)
+					copy(copied, hashes)
+					rootHash := id.NewMerkleHash(hashes)
+					parallelRootHash := id.NewMerkleHashParallel(hashes, int(numWorkers%16))
+					Expect(parallelRootHash).To(Equal(rootHash))
+					Expect(hashes).To(Equal(copied))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should return the same merkle hash for large inputs", func() {
+				for _, n := range []int{1 << 16, 1<<16 + 1, 100001} {
+					hashes := make([]id.Hash, n)
+					for i := range hashes {
+						rand.Read(hashes[i][:])
+					}
+					rootHash := id.NewMerkleHash(hashes)
+					for _, numWorkers := range []int{0, 1, 2, 3, 7, 64} {
+						Expect(id.NewMerkleHashParallel(hashes, numWorkers)).To(Equal(rootHash))
+					}
+				}
+			})
+
+			It("should equal the signatory equivalent", func() {
+				f := func(n uint) bool {
+					n = n % 10000
+					signatories := make([]id.Signatory, n)
+					for i := range signatories {
+						rand.Read(signatories[i][:])
+					}
+					expected := id.NewMerkleHashFromSignatories(signatories)
+					got := id.NewMerkleHashFromSignatoriesParallel(signatories, 4)
+					Expect(got).To(Equal(expected))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+		})
+
 		Context("when using signatories", func() {
 			It("should equal the hash equivalent", func() {
 				f := func(n uint) bool {
@@ -252,3 +300,61 @@ func BenchmarkMerkleHashSafe1000(b *testing.B) {
 		id.NewMerkleHashSafe(hashes)
 	}
 }
+
+func BenchmarkMerkleHashParallel(b *testing.B) {
+	hashes := make([]id.Hash, b.N)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	id.NewMerkleHashParallel(hashes, 0)
+}
+
+func BenchmarkMerkleHashParallel1000(b *testing.B) {
+	hashes := make([]id.Hash, 1000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id.NewMerkleHashParallel(hashes, 0)
+	}
+}
+
+func BenchmarkMerkleHash1000000(b *testing.B) {
+	hashes := make([]id.Hash, 1000000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id.NewMerkleHash(hashes)
+	}
+}
+
+func BenchmarkMerkleHashSafe1000000(b *testing.B) {
+	hashes := make([]id.Hash, 1000000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id.NewMerkleHashSafe(hashes)
+	}
+}
+
+func BenchmarkMerkleHashParallel1000000(b *testing.B) {
+	hashes := make([]id.Hash, 1000000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id.NewMerkleHashParallel(hashes, 0)
+	}
+}
@@ -0,0 +1,126 @@
+package id
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// A Hasher is a hashing function that produces 256-bit hashes. It is used to
+// parameterise the derivation of Hashes (for example, in Blobs, merkle trees,
+// and Signatories) when interoperating with systems that do not use the
+// 256-bit SHA2 hashing function.
+type Hasher interface {
+	// Sum returns the hash of the data.
+	Sum(data []byte) Hash
+	// New returns a hash.Hash that can be used to incrementally hash data.
+	// The hash.Hash must produce 256-bit sums.
+	New() hash.Hash
+}
+
+// Hashers for commonly used 256-bit hashing functions. The SHA256Hasher is the
+// default, and is used by all functions that do not accept a Hasher.
+var (
+	SHA256Hasher     Hasher = sha256Hasher{}
+	Keccak256Hasher  Hasher = keccak256Hasher{}
+	SHA3256Hasher    Hasher = sha3256Hasher{}
+	Blake2b256Hasher Hasher = blake2b256Hasher{}
+)
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Sum(data []byte) Hash {
+	return sha256.Sum256(data)
+}
+
+func (sha256Hasher) New() hash.Hash {
+	return sha256.New()
+}
+
+type keccak256Hasher struct{}
+
+func (keccak256Hasher) Sum(data []byte) Hash {
+	hash := Hash{}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	h.Sum(hash[:0])
+	return hash
+}
+
+func (keccak256Hasher) New() hash.Hash {
+	return sha3.NewLegacyKeccak256()
+}
+
+type sha3256Hasher struct{}
+
+func (sha3256Hasher) Sum(data []byte) Hash {
+	return sha3.Sum256(data)
+}
+
+func (sha3256Hasher) New() hash.Hash {
+	return sha3.New256()
+}
+
+type blake2b256Hasher struct{}
+
+func (blake2b256Hasher) Sum(data []byte) Hash {
+	return blake2b.Sum256(data)
+}
+
+func (blake2b256Hasher) New() hash.Hash {
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		// Defensive check. An error is only returned when the key is too
+		// long, but no key is used.
+		panic(fmt.Errorf("creating blake2b: %v", err))
+	}
+	return h
+}
+
+// NewHashWithHasher consumes a slice of bytes and hashes it using the given
+// Hasher.
+func NewHashWithHasher(data []byte, hasher Hasher) Hash {
+	return hasher.Sum(data)
+}
+
+// NewMerkleHashWithHasher is the same as NewMerkleHash, but it uses the given
+// Hasher to hash pairs of hashes. The input slice is unmodified.
+func NewMerkleHashWithHasher(hashes []Hash, hasher Hasher) Hash {
+	dst := make([]Hash, len(hashes))
+	copy(dst, hashes)
+	return NewMerkleHashInPlaceWithHasher(dst, hasher)
+}
+
+// NewMerkleHashInPlaceWithHasher is the same as NewMerkleHashWithHasher but it
+// overrides values in the input slice for efficiency. Only use this function if
+// you do not need the input slices.
+func NewMerkleHashInPlaceWithHasher(hashes []Hash, hasher Hasher) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+	buf := [64]byte{}
+	for l := len(hashes) / 2; l >= 1; l = len(hashes) / 2 {
+		b := len(hashes) & 1
+		for i := 0; i < l; i++ {
+			copy(buf[:32], hashes[b+i*2][:])
+			copy(buf[32:], hashes[b+i*2+1][:])
+			hashes[b+i] = hasher.Sum(buf[:])
+		}
+		hashes = hashes[:b+l]
+	}
+	return hashes[0]
+}
+
+// NewMerkleHashFromSignatoriesWithHasher is the same as
+// NewMerkleHashWithHasher but it accepts a slice of Signatories instead of a
+// slice of Hashes.
+func NewMerkleHashFromSignatoriesWithHasher(signatories []Signatory, hasher Hasher) Hash {
+	dst := make([]Hash, len(signatories))
+	for i := range signatories {
+		dst[i] = Hash(signatories[i])
+	}
+	return NewMerkleHashInPlaceWithHasher(dst, hasher)
+}
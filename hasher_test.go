@@ -0,0 +1,158 @@
+package id_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hashers", func() {
+	// prefixHashes returns the hashes of every prefix of the data.
+	prefixHashes := func(data []byte) []id.Hash {
+		hashes := make([]id.Hash, len(data))
+		for i := range hashes {
+			hashes[i] = id.NewHash(data[:i])
+		}
+		return hashes
+	}
+
+	hashers := []struct {
+		name   string
+		hasher id.Hasher
+		abc    string
+	}{
+		{"SHA256", id.SHA256Hasher, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"Keccak256", id.Keccak256Hasher, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{"SHA3256", id.SHA3256Hasher, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+		{"Blake2b256", id.Blake2b256Hasher, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+	}
+
+	for _, h := range hashers {
+		h := h
+
+		Context("when hashing data using "+h.name, func() {
+			It("should return the known hash", func() {
+				hash := id.NewHashWithHasher([]byte("abc"), h.hasher)
+				Expect(hex.EncodeToString(hash[:])).To(Equal(h.abc))
+			})
+
+			It("should return the same hash as the incremental hasher", func() {
+				f := func(data []byte, split uint8) bool {
+					k := int(split) % (len(data) + 1)
+					w := h.hasher.New()
+					Expect(w.Size()).To(Equal(id.SizeHintHash))
+					w.Write(data[:k])
+					w.Write(data[k:])
+					hash := h.hasher.Sum(data)
+					Expect(w.Sum(nil)).To(Equal(hash[:]))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+		})
+
+		Context("when computing merkle hashes using "+h.name, func() {
+			It("should hash pairs of hashes using the hasher", func() {
+				f := func(data []byte) bool {
+					hashes := prefixHashes(data)
+					if len(hashes) < 2 {
+						return true
+					}
+					buf := make([]byte, 0, 64)
+					buf = append(buf, hashes[0][:]...)
+					buf = append(buf, hashes[1][:]...)
+					expected := h.hasher.Sum(buf)
+					got := id.NewMerkleHashWithHasher(hashes[:2], h.hasher)
+					Expect(got).To(Equal(expected))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should not modify the input", func() {
+				f := func(data []byte) bool {
+					hashes := prefixHashes(data)
+					copied := make([]id.Hash, len(hashes))
+					copy(copied, hashes)
+					id.NewMerkleHashWithHasher(hashes, h.hasher)
+					Expect(hashes).To(Equal(copied))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+
+			It("should return the same hash for hashes and signatories", func() {
+				f := func(data []byte) bool {
+					hashes := prefixHashes(data)
+					signatories := make([]id.Signatory, len(hashes))
+					for i := range hashes {
+						signatories[i] = id.Signatory(hashes[i])
+					}
+					expected := id.NewMerkleHashWithHasher(hashes, h.hasher)
+					got := id.NewMerkleHashFromSignatoriesWithHasher(signatories, h.hasher)
+					Expect(got).To(Equal(expected))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+		})
+
+		Context("when hashing a blob using "+h.name, func() {
+			It("should return the hash of the binary representation", func() {
+				f := func(data []byte) bool {
+					blob := id.NewBlobWithHasher(bytesMarshaler(data), h.hasher)
+					marshaled, err := surge.ToBinary(bytesMarshaler(data))
+					Expect(err).ToNot(HaveOccurred())
+					hash, err := blob.Hash()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(hash).To(Equal(h.hasher.Sum(marshaled)))
+					return true
+				}
+				Expect(quick.Check(f, nil)).To(Succeed())
+			})
+		})
+	}
+
+	Context("when using the SHA256 hasher", func() {
+		It("should be the same as the default functions", func() {
+			f := func(data []byte) bool {
+				Expect(id.NewHashWithHasher(data, id.SHA256Hasher)).To(Equal(id.Hash(sha256.Sum256(data))))
+
+				hashes := prefixHashes(data)
+				Expect(id.NewMerkleHashWithHasher(hashes, id.SHA256Hasher)).To(Equal(id.NewMerkleHash(hashes)))
+
+				blobHash, err := id.NewBlobWithHasher(bytesMarshaler(data), id.SHA256Hasher).Hash()
+				Expect(err).ToNot(HaveOccurred())
+				expectedBlobHash, err := id.NewBlob(bytesMarshaler(data)).Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobHash).To(Equal(expectedBlobHash))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when deriving signatories", func() {
+		It("should be the same as the default when using the SHA256 hasher", func() {
+			privKey := id.NewPrivKey()
+			pubKey := privKey.PubKey()
+			Expect(id.NewSignatoryWithHasher(pubKey, id.SHA256Hasher)).To(Equal(id.NewSignatory(pubKey)))
+		})
+
+		It("should be the ethereum address when using the keccak256 hasher", func() {
+			privKey := id.NewPrivKey()
+			pubKey := privKey.PubKey()
+			signatory := id.NewSignatoryWithHasher(pubKey, id.Keccak256Hasher)
+			address := crypto.PubkeyToAddress(ecdsa.PublicKey(*pubKey))
+			Expect(signatory[12:]).To(Equal(address[:]))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package id
+
+import (
+	"hash"
+	"io"
+	"os"
+)
+
+// A HashWriter incrementally hashes the data written to it, so that large
+// amounts of data can be hashed without loading them into memory. It
+// implements the hash.Hash interface. By default, the 256-bit SHA2 hashing
+// function is used, and the resulting Hash is the same as calling NewHash on
+// all of the written data.
+type HashWriter struct {
+	inner hash.Hash
+}
+
+// NewHashWriter returns a HashWriter that uses the 256-bit SHA2 hashing
+// function.
+func NewHashWriter() *HashWriter {
+	return NewHashWriterWithHasher(SHA256Hasher)
+}
+
+// NewHashWriterWithHasher returns a HashWriter that uses the given Hasher.
+func NewHashWriterWithHasher(hasher Hasher) *HashWriter {
+	return &HashWriter{inner: hasher.New()}
+}
+
+// Write adds more data to the HashWriter. It never returns an error.
+func (w *HashWriter) Write(data []byte) (int, error) {
+	return w.inner.Write(data)
+}
+
+// Sum appends the current hash to b and returns the resulting slice. It does
+// not change the underlying state.
+func (w *HashWriter) Sum(b []byte) []byte {
+	return w.inner.Sum(b)
+}
+
+// Reset the HashWriter to its initial state.
+func (w *HashWriter) Reset() {
+	w.inner.Reset()
+}
+
+// Size returns the number of bytes Sum will return.
+func (w *HashWriter) Size() int {
+	return w.inner.Size()
+}
+
+// BlockSize returns the underlying block size of the hashing function.
+func (w *HashWriter) BlockSize() int {
+	return w.inner.BlockSize()
+}
+
+// Hash returns the Hash of all of the data that has been written to the
+// HashWriter. It does not change the underlying state.
+func (w *HashWriter) Hash() Hash {
+	hash := Hash{}
+	w.inner.Sum(hash[:0])
+	return hash
+}
+
+// NewHashFromReader reads all of the data from the reader and hashes it using
+// the 256-bit SHA2 hashing function. The resulting Hash is the same as calling
+// NewHash on all of the data. An error is returned if the reader returns an
+// error other than io.EOF.
+func NewHashFromReader(r io.Reader) (Hash, error) {
+	return NewHashFromReaderWithHasher(r, SHA256Hasher)
+}
+
+// NewHashFromReaderWithHasher is the same as NewHashFromReader, but it uses the
+// given Hasher.
+func NewHashFromReaderWithHasher(r io.Reader, hasher Hasher) (Hash, error) {
+	w := NewHashWriterWithHasher(hasher)
+	if _, err := io.Copy(w, r); err != nil {
+		return Hash{}, err
+	}
+	return w.Hash(), nil
+}
+
+// NewHashFromFile reads all of the data from the file at the given path and
+// hashes it using the 256-bit SHA2 hashing function. The file is streamed, so
+// it is never loaded into memory all at once.
+func NewHashFromFile(path string) (Hash, error) {
+	return NewHashFromFileWithHasher(path, SHA256Hasher)
+}
+
+// NewHashFromFileWithHasher is the same as NewHashFromFile, but it uses the
+// given Hasher.
+func NewHashFromFileWithHasher(path string, hasher Hasher) (Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close()
+	return NewHashFromReaderWithHasher(f, hasher)
+}
@@ -0,0 +1,114 @@
+package id_test
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing/iotest"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hash writers", func() {
+	Context("when writing data in chunks", func() {
+		It("should return the same hash as NewHash", func() {
+			f := func(data []byte, split uint8) bool {
+				k := int(split) % (len(data) + 1)
+				w := id.NewHashWriter()
+				w.Write(data[:k])
+				w.Write(data[k:])
+				hash := w.Hash()
+				Expect(hash).To(Equal(id.NewHash(data)))
+				Expect(w.Sum(nil)).To(Equal(hash[:]))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return the same hash after being reset", func() {
+			f := func(garbage, data []byte) bool {
+				w := id.NewHashWriter()
+				w.Write(garbage)
+				w.Reset()
+				w.Write(data)
+				Expect(w.Hash()).To(Equal(id.NewHash(data)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should implement the hash interface", func() {
+			var w hash.Hash = id.NewHashWriter()
+			Expect(w.Size()).To(Equal(id.SizeHintHash))
+			Expect(w.BlockSize()).To(Equal(64))
+		})
+	})
+
+	Context("when hashing a reader", func() {
+		It("should return the same hash as NewHash", func() {
+			f := func(data []byte) bool {
+				hash, err := id.NewHashFromReader(iotest.OneByteReader(bytes.NewReader(data)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(data)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return the same hash as the hasher", func() {
+			f := func(data []byte) bool {
+				hash, err := id.NewHashFromReaderWithHasher(bytes.NewReader(data), id.Keccak256Hasher)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.Keccak256Hasher.Sum(data)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error if the reader returns an error", func() {
+			r := iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader([]byte{1, 2, 3})))
+			_, err := id.NewHashFromReader(r)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when hashing a file", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "id")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should return the same hash as NewHash", func() {
+			i := 0
+			f := func(data []byte) bool {
+				path := filepath.Join(dir, fmt.Sprintf("%v", i))
+				i++
+				Expect(ioutil.WriteFile(path, data, 0600)).To(Succeed())
+				hash, err := id.NewHashFromFile(path)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash).To(Equal(id.NewHash(data)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error if the file does not exist", func() {
+			_, err := id.NewHashFromFile(filepath.Join(dir, "missing"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
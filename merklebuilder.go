@@ -0,0 +1,143 @@
+package id
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// A MerkleBuilder incrementally computes the root hash of the merkle tree
+// built by NewMerkleHash, consuming the leaves one at a time.
+//
+// Because NewMerkleHash pairs hashes from left-to-right, with odd hashes
+// trailing at the front, the shape of the tree depends on the total number of
+// leaves. For example, the first leaf is paired with the second leaf when there
+// are four leaves, but it is not paired with anything when there are five
+// leaves. When the number of leaves is known in advance (see
+// NewMerkleBuilderWithLen), the MerkleBuilder uses memory that is logarithmic
+// in the number of leaves. Otherwise (see NewMerkleBuilder), it must buffer all
+// of the leaves until the root hash is requested.
+type MerkleBuilder struct {
+	n        uint64
+	expected uint64
+
+	// The leaves of a merkle tree with an unknown number of leaves are
+	// buffered. This is nil if, and only if, the number of leaves is known.
+	buffered []Hash
+
+	// The leaves of a merkle tree with a known number of leaves are split,
+	// from left-to-right, into perfect subtrees of non-decreasing size (see
+	// merkleSubtreeSizes). The root hash is then the left-fold of the subtree
+	// roots.
+	subtrees []uint64
+	stack    []merkleBuilderNode
+	acc      Hash
+	accOK    bool
+}
+
+type merkleBuilderNode struct {
+	hash   Hash
+	height int
+}
+
+// NewMerkleBuilder returns a MerkleBuilder for an unknown number of leaves.
+// All appended leaves are buffered in memory, and the root hash is computed
+// when it is requested.
+func NewMerkleBuilder() *MerkleBuilder {
+	return &MerkleBuilder{buffered: []Hash{}}
+}
+
+// NewMerkleBuilderWithLen returns a MerkleBuilder for a known number of
+// leaves. Leaves are hashed as they are appended, and the memory used is
+// logarithmic in the number of leaves. Exactly n leaves must be appended
+// before the root hash can be requested.
+func NewMerkleBuilderWithLen(n uint64) *MerkleBuilder {
+	return &MerkleBuilder{
+		expected: n,
+		subtrees: merkleSubtreeSizes(n),
+		stack:    make([]merkleBuilderNode, 0, bits.Len64(n)),
+	}
+}
+
+// Len returns the number of leaves that have been appended.
+func (builder *MerkleBuilder) Len() uint64 {
+	return builder.n
+}
+
+// Append a leaf to the merkle tree. An error is returned if the MerkleBuilder
+// was created with a known number of leaves, and that number has already been
+// appended.
+func (builder *MerkleBuilder) Append(hash Hash) error {
+	if builder.buffered != nil {
+		builder.buffered = append(builder.buffered, hash)
+		builder.n++
+		return nil
+	}
+	if builder.n >= builder.expected {
+		return fmt.Errorf("expected len=%v, got len=%v", builder.expected, builder.n+1)
+	}
+	builder.n++
+
+	// Merge perfect subtrees of the same height, until the current subtree is
+	// complete.
+	builder.stack = append(builder.stack, merkleBuilderNode{hash: hash, height: 0})
+	for len(builder.stack) >= 2 {
+		left := &builder.stack[len(builder.stack)-2]
+		right := &builder.stack[len(builder.stack)-1]
+		if left.height != right.height {
+			break
+		}
+		left.hash = hashMerklePair(&left.hash, &right.hash)
+		left.height++
+		builder.stack = builder.stack[:len(builder.stack)-1]
+	}
+	if uint64(1)<<uint(builder.stack[0].height) != builder.subtrees[0] {
+		return nil
+	}
+
+	// The current subtree is complete, so fold it into the accumulated root
+	// hash.
+	if builder.accOK {
+		builder.acc = hashMerklePair(&builder.acc, &builder.stack[0].hash)
+	} else {
+		builder.acc = builder.stack[0].hash
+		builder.accOK = true
+	}
+	builder.stack = builder.stack[:0]
+	builder.subtrees = builder.subtrees[1:]
+	return nil
+}
+
+// Root returns the root hash of the merkle tree. It is the same as calling
+// NewMerkleHash with all of the appended leaves. An error is returned if the
+// MerkleBuilder was created with a known number of leaves, and fewer leaves
+// have been appended.
+func (builder *MerkleBuilder) Root() (Hash, error) {
+	if builder.buffered != nil {
+		return NewMerkleHash(builder.buffered), nil
+	}
+	if builder.n != builder.expected {
+		return Hash{}, fmt.Errorf("expected len=%v, got len=%v", builder.expected, builder.n)
+	}
+	return builder.acc, nil
+}
+
+// merkleSubtreeSizes returns the sizes of the perfect subtrees that make up
+// the merkle tree built by NewMerkleHash over n leaves, from left-to-right.
+// The right child of the root is a perfect subtree over the last 2^(h-1)
+// leaves, where h is the height of the tree, and the left child of the root is
+// the merkle tree over the remaining leaves.
+func merkleSubtreeSizes(n uint64) []uint64 {
+	sizes := []uint64{}
+	for n > 1 {
+		size := uint64(1) << uint(bits.Len64(n-1)-1)
+		sizes = append(sizes, size)
+		n -= size
+	}
+	if n == 1 {
+		sizes = append(sizes, 1)
+	}
+	for i, j := 0, len(sizes)-1; i < j; i, j = i+1, j-1 {
+		sizes[i], sizes[j] = sizes[j], sizes[i]
+	}
+	return sizes
+}
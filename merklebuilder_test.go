@@ -0,0 +1,104 @@
+package id_test
+
+import (
+	"crypto/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merkle builders", func() {
+	Context("when the number of leaves is known", func() {
+		It("should equal the merkle hash", func() {
+			for n := 0; n <= 300; n++ {
+				hashes := randomHashes(n)
+				builder := id.NewMerkleBuilderWithLen(uint64(n))
+				for i := range hashes {
+					Expect(builder.Append(hashes[i])).To(Succeed())
+				}
+				Expect(builder.Len()).To(Equal(uint64(n)))
+				root, err := builder.Root()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal(id.NewMerkleHash(hashes)))
+			}
+		})
+
+		It("should equal the merkle hash for random lengths", func() {
+			f := func(n uint) bool {
+				n = n % 10000
+				hashes := randomHashes(int(n))
+				builder := id.NewMerkleBuilderWithLen(uint64(n))
+				for i := range hashes {
+					Expect(builder.Append(hashes[i])).To(Succeed())
+				}
+				root, err := builder.Root()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal(id.NewMerkleHash(hashes)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when appending too many leaves", func() {
+			f := func(n uint) bool {
+				n = n % 1000
+				builder := id.NewMerkleBuilderWithLen(uint64(n))
+				for _, hash := range randomHashes(int(n)) {
+					Expect(builder.Append(hash)).To(Succeed())
+				}
+				Expect(builder.Append(id.Hash{})).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when requesting the root too early", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				builder := id.NewMerkleBuilderWithLen(uint64(n))
+				for _, hash := range randomHashes(int(n - 1)) {
+					Expect(builder.Append(hash)).To(Succeed())
+				}
+				_, err := builder.Root()
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when the number of leaves is unknown", func() {
+		It("should equal the merkle hash after every append", func() {
+			hashes := randomHashes(300)
+			builder := id.NewMerkleBuilder()
+			root, err := builder.Root()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(root).To(Equal(id.Hash{}))
+			for i := range hashes {
+				Expect(builder.Append(hashes[i])).To(Succeed())
+				root, err := builder.Root()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal(id.NewMerkleHash(hashes[:i+1])))
+			}
+			Expect(builder.Len()).To(Equal(uint64(len(hashes))))
+		})
+	})
+})
+
+func BenchmarkMerkleBuilder(b *testing.B) {
+	hashes := make([]id.Hash, b.N)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	builder := id.NewMerkleBuilderWithLen(uint64(b.N))
+	for i := range hashes {
+		builder.Append(hashes[i])
+	}
+	builder.Root()
+}
@@ -0,0 +1,235 @@
+package id
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+
+	"github.com/renproject/surge"
+)
+
+// NewMerkleHashLog returns the root hash of the append-only log merkle tree
+// that uses the hashes as leaves. The input slice is unmodified.
+//
+// The merkle tree built by NewMerkleHash cannot be used to efficiently prove
+// that one list of hashes is an extension of another, because its shape
+// depends on the total number of leaves: appending a single leaf can change
+// every internal node. Instead, the log merkle tree uses the layout defined in
+// RFC 6962 (Certificate Transparency), where the left subtree of the root is
+// always the perfect merkle tree over the largest power of two number of
+// leaves that is strictly less than the total number of leaves. This means
+// that the internal nodes of the left-most perfect subtrees never change once
+// they are complete. Leaves and internal nodes are hashed in the same way as
+// NewMerkleHashHardened.
+//
+// Five hashes:
+//
+//      /\
+//     /  \
+//    /\   \
+//   /\/\   \
+//
+// Seven hashes:
+//
+//      /\
+//     /  \
+//    /\  /\
+//   /\/\/\ \
+//
+func NewMerkleHashLog(hashes []Hash) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+	return merkleHashLog(hashes)
+}
+
+func merkleHashLog(hashes []Hash) Hash {
+	if len(hashes) == 1 {
+		return hashMerkleLeafHardened(&hashes[0])
+	}
+	k := merkleLogSplit(uint64(len(hashes)))
+	left := merkleHashLog(hashes[:k])
+	right := merkleHashLog(hashes[k:])
+	return hashMerklePairHardened(&left, &right)
+}
+
+// A MerkleConsistencyProof proves that the log merkle tree over the first
+// OldLen leaves is a prefix of the log merkle tree over NewLen leaves. The Path
+// stores the hashes of the subtrees that are needed to recompute both root
+// hashes, as defined by RFC 6962.
+type MerkleConsistencyProof struct {
+	OldLen uint64
+	NewLen uint64
+	Path   []Hash
+}
+
+// NewMerkleConsistencyProof returns the MerkleConsistencyProof that the log
+// merkle tree over the first oldLen hashes is a prefix of the log merkle tree
+// over all of the hashes (see NewMerkleHashLog). The input slice is
+// unmodified. An error is returned if oldLen is zero, or greater than the
+// number of hashes.
+func NewMerkleConsistencyProof(hashes []Hash, oldLen int) (MerkleConsistencyProof, error) {
+	if oldLen <= 0 || oldLen > len(hashes) {
+		return MerkleConsistencyProof{}, fmt.Errorf("expected 0<len<=%v, got len=%v", len(hashes), oldLen)
+	}
+	proof := MerkleConsistencyProof{
+		OldLen: uint64(oldLen),
+		NewLen: uint64(len(hashes)),
+	}
+	if oldLen < len(hashes) {
+		proof.Path = merkleConsistencySubproof(uint64(oldLen), hashes, true, []Hash{})
+	}
+	return proof, nil
+}
+
+// merkleConsistencySubproof implements SUBPROOF from RFC 6962, appending the
+// subtree hashes to the path.
+func merkleConsistencySubproof(m uint64, hashes []Hash, complete bool, path []Hash) []Hash {
+	n := uint64(len(hashes))
+	if m == n {
+		if complete {
+			return path
+		}
+		return append(path, merkleHashLog(hashes))
+	}
+	k := merkleLogSplit(n)
+	if m <= k {
+		path = merkleConsistencySubproof(m, hashes[:k], complete, path)
+		return append(path, merkleHashLog(hashes[k:]))
+	}
+	path = merkleConsistencySubproof(m-k, hashes[k:], false, path)
+	return append(path, merkleHashLog(hashes[:k]))
+}
+
+// VerifyMerkleConsistencyProof returns true if the MerkleConsistencyProof
+// proves that the log merkle tree with the old root hash is a prefix of the
+// log merkle tree with the new root hash, otherwise it returns false.
+func VerifyMerkleConsistencyProof(oldRoot, newRoot *Hash, proof *MerkleConsistencyProof) bool {
+	if proof.OldLen == 0 || proof.OldLen > proof.NewLen || len(proof.Path) > 2*MaxMerkleProofPathLen {
+		return false
+	}
+	if proof.OldLen == proof.NewLen {
+		return len(proof.Path) == 0 && oldRoot.Equal(newRoot)
+	}
+	if len(proof.Path) == 0 {
+		return false
+	}
+
+	// This follows the verification algorithm defined in RFC 9162.
+	path := proof.Path
+	if proof.OldLen&(proof.OldLen-1) == 0 {
+		path = append([]Hash{*oldRoot}, path...)
+	}
+	fn := proof.OldLen - 1
+	sn := proof.NewLen - 1
+	shift := bits.TrailingZeros64(^fn)
+	fn >>= uint(shift)
+	sn >>= uint(shift)
+
+	fr, sr := path[0], path[0]
+	for i := 1; i < len(path); i++ {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = hashMerklePairHardened(&path[i], &fr)
+			sr = hashMerklePairHardened(&path[i], &sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = hashMerklePairHardened(&sr, &path[i])
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && fr.Equal(oldRoot) && sr.Equal(newRoot)
+}
+
+// Equal compares one MerkleConsistencyProof with another. If they are equal,
+// then it returns true, otherwise it returns false.
+func (proof MerkleConsistencyProof) Equal(other *MerkleConsistencyProof) bool {
+	if proof.OldLen != other.OldLen || proof.NewLen != other.NewLen || len(proof.Path) != len(other.Path) {
+		return false
+	}
+	for i := range proof.Path {
+		if !proof.Path[i].Equal(&other.Path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a
+// MerkleConsistencyProof in binary.
+func (proof MerkleConsistencyProof) SizeHint() int {
+	return 2*surge.SizeHintU64 + surge.SizeHintU16 + len(proof.Path)*SizeHintHash
+}
+
+// Marshal into binary.
+func (proof MerkleConsistencyProof) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if len(proof.Path) > 2*MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", 2*MaxMerkleProofPathLen, len(proof.Path))
+	}
+	buf, rem, err := surge.MarshalU64(proof.OldLen, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.MarshalU64(proof.NewLen, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(proof.Path, buf, rem)
+}
+
+// Unmarshal from binary.
+func (proof *MerkleConsistencyProof) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.UnmarshalU64(&proof.OldLen, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.UnmarshalU64(&proof.NewLen, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	pathLen := uint16(0)
+	if _, _, err := surge.UnmarshalU16(&pathLen, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if pathLen > 2*MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", 2*MaxMerkleProofPathLen, pathLen)
+	}
+	return surge.Unmarshal(&proof.Path, buf, rem)
+}
+
+// MarshalJSON implements the JSON marshaler interface for the
+// MerkleConsistencyProof type. It is represented as an unpadded base64 string
+// of its binary representation.
+func (proof MerkleConsistencyProof) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(proof)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the
+// MerkleConsistencyProof type. It assumes that it has been represented as an
+// unpadded base64 string of its binary representation.
+func (proof *MerkleConsistencyProof) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(proof, decoded)
+}
+
+// merkleLogSplit returns the largest power of two that is strictly less than
+// n. It assumes that n is greater than one.
+func merkleLogSplit(n uint64) uint64 {
+	return uint64(1) << uint(bits.Len64(n-1)-1)
+}
@@ -0,0 +1,175 @@
+package id_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merkle consistency proofs", func() {
+	// referenceHashLog is a direct implementation of the merkle tree hash
+	// defined in RFC 6962.
+	var referenceHashLog func(hashes []id.Hash) id.Hash
+	referenceHashLog = func(hashes []id.Hash) id.Hash {
+		if len(hashes) == 1 {
+			return id.Hash(sha256.Sum256(append([]byte{0x00}, hashes[0][:]...)))
+		}
+		k := 1
+		for 2*k < len(hashes) {
+			k *= 2
+		}
+		left, right := referenceHashLog(hashes[:k]), referenceHashLog(hashes[k:])
+		return id.Hash(sha256.Sum256(append(append([]byte{0x01}, left[:]...), right[:]...)))
+	}
+
+	Context("when computing the log merkle hash", func() {
+		It("should equal the reference implementation", func() {
+			Expect(id.NewMerkleHashLog([]id.Hash{})).To(Equal(id.Hash{}))
+			for n := 1; n <= 100; n++ {
+				hashes := randomHashes(n)
+				Expect(id.NewMerkleHashLog(hashes)).To(Equal(referenceHashLog(hashes)))
+			}
+		})
+
+		It("should equal the hardened merkle hash when the number of leaves is a power of two", func() {
+			for n := 1; n <= 1024; n *= 2 {
+				hashes := randomHashes(n)
+				Expect(id.NewMerkleHashLog(hashes)).To(Equal(id.NewMerkleHashHardened(hashes)))
+			}
+		})
+	})
+
+	Context("when proving consistency", func() {
+		It("should verify for every pair of lengths", func() {
+			hashes := randomHashes(64)
+			for m := 1; m <= len(hashes); m++ {
+				newRoot := id.NewMerkleHashLog(hashes[:m])
+				for n := 1; n <= m; n++ {
+					oldRoot := id.NewMerkleHashLog(hashes[:n])
+					proof, err := id.NewMerkleConsistencyProof(hashes[:m], n)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &proof)).To(BeTrue())
+				}
+			}
+		})
+
+		It("should not verify a rewritten history", func() {
+			f := func(m, n, i uint16) bool {
+				m = m%1000 + 2
+				n = n%(m-1) + 1
+				i = i % n
+				hashes := randomHashes(int(m))
+				proof, err := id.NewMerkleConsistencyProof(hashes, int(n))
+				Expect(err).ToNot(HaveOccurred())
+				newRoot := id.NewMerkleHashLog(hashes)
+
+				// Rewrite one of the old leaves.
+				rewritten := make([]id.Hash, n)
+				copy(rewritten, hashes[:n])
+				rand.Read(rewritten[i][:])
+				oldRoot := id.NewMerkleHashLog(rewritten)
+				Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a modified proof", func() {
+			f := func(m, n uint16) bool {
+				m = m%1000 + 2
+				n = n%(m-1) + 1
+				hashes := randomHashes(int(m))
+				proof, err := id.NewMerkleConsistencyProof(hashes, int(n))
+				Expect(err).ToNot(HaveOccurred())
+				oldRoot := id.NewMerkleHashLog(hashes[:n])
+				newRoot := id.NewMerkleHashLog(hashes)
+				Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &proof)).To(BeTrue())
+
+				for i := range proof.Path {
+					modified := proof
+					modified.Path = make([]id.Hash, len(proof.Path))
+					copy(modified.Path, proof.Path)
+					modified.Path[i][0] ^= 1
+					Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &modified)).To(BeFalse())
+				}
+
+				modified := proof
+				modified.OldLen = 0
+				Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &modified)).To(BeFalse())
+				modified = proof
+				modified.NewLen = modified.OldLen
+				Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &modified)).To(BeFalse())
+				modified = proof
+				modified.Path = modified.Path[:len(modified.Path)-1]
+				Expect(id.VerifyMerkleConsistencyProof(&oldRoot, &newRoot, &modified)).To(BeFalse())
+				Expect(id.VerifyMerkleConsistencyProof(&newRoot, &oldRoot, &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should only verify equal roots when the lengths are equal", func() {
+			hashes := randomHashes(10)
+			proof, err := id.NewMerkleConsistencyProof(hashes, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proof.Path).To(BeEmpty())
+			root := id.NewMerkleHashLog(hashes)
+			other := id.NewMerkleHashLog(hashes[:9])
+			Expect(id.VerifyMerkleConsistencyProof(&root, &root, &proof)).To(BeTrue())
+			Expect(id.VerifyMerkleConsistencyProof(&other, &root, &proof)).To(BeFalse())
+		})
+
+		It("should return an error when the old length is out of range", func() {
+			hashes := randomHashes(10)
+			_, err := id.NewMerkleConsistencyProof(hashes, 0)
+			Expect(err).To(HaveOccurred())
+			_, err = id.NewMerkleConsistencyProof(hashes, 11)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling", func() {
+		It("should equal itself", func() {
+			f := func(m, n uint16) bool {
+				m = m%1000 + 1
+				n = n%m + 1
+				proof, err := id.NewMerkleConsistencyProof(randomHashes(int(m)), int(n))
+				Expect(err).ToNot(HaveOccurred())
+
+				marshaled, err := surge.ToBinary(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleConsistencyProof{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+
+				marshaledJSON, err := json.Marshal(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled = id.MerkleConsistencyProof{}
+				Expect(json.Unmarshal(marshaledJSON, &unmarshaled)).To(Succeed())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error for random bytes", func() {
+			f := func(data []byte) bool {
+				if len(data) >= 18 {
+					return true
+				}
+				unmarshaled := id.MerkleConsistencyProof{}
+				Expect(surge.FromBinary(&unmarshaled, data)).ToNot(Succeed())
+				Expect(unmarshaled.UnmarshalJSON(data)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
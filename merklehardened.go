@@ -0,0 +1,89 @@
+package id
+
+import (
+	"crypto/sha256"
+)
+
+// Domain separation prefixes used by the hardened merkle tree. They are the
+// same as the prefixes defined in RFC 6962.
+const (
+	MerkleLeafPrefix = byte(0x00)
+	MerkleNodePrefix = byte(0x01)
+)
+
+// NewMerkleHashHardened returns the root hash of the hardened merkle tree that
+// uses the hashes as leaves. The tree has the same shape as the tree built by
+// NewMerkleHash, but leaves are hashed as SHA2-256(0x00 || leaf) and internal
+// nodes are hashed as SHA2-256(0x01 || left || right). This prevents a leaf
+// from being confused with an internal node (and vice versa), which would
+// otherwise allow second-preimage attacks. The input slice is unmodified.
+//
+// The root hash is not the same as the root hash returned by NewMerkleHash,
+// so protocols must opt-in to using the hardened merkle tree.
+func NewMerkleHashHardened(hashes []Hash) Hash {
+	dst := make([]Hash, len(hashes))
+	copy(dst, hashes)
+	return NewMerkleHashInPlaceHardened(dst)
+}
+
+// NewMerkleHashInPlaceHardened returns the root hash of the hardened merkle
+// tree that uses the hashes as leaves. It is the same as NewMerkleHashHardened
+// but it overrides values in the input slice for efficiency. Only use this
+// function if you do not need the input slices.
+func NewMerkleHashInPlaceHardened(hashes []Hash) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+	for i := range hashes {
+		hashes[i] = hashMerkleLeafHardened(&hashes[i])
+	}
+	for l := len(hashes) / 2; l >= 1; l = len(hashes) / 2 {
+		b := len(hashes) & 1
+		for i := 0; i < l; i++ {
+			hashes[b+i] = hashMerklePairHardened(&hashes[b+i*2], &hashes[b+i*2+1])
+		}
+		hashes = hashes[:b+l]
+	}
+	return hashes[0]
+}
+
+// NewMerkleHashFromSignatoriesHardened is the same as NewMerkleHashHardened
+// but it accepts a slice of Signatories instead of a slice of Hashes.
+func NewMerkleHashFromSignatoriesHardened(signatories []Signatory) Hash {
+	dst := make([]Hash, len(signatories))
+	for i := range signatories {
+		dst[i] = Hash(signatories[i])
+	}
+	return NewMerkleHashInPlaceHardened(dst)
+}
+
+// NewMerkleProofHardened returns the MerkleProof that the hash at the given
+// index is a leaf of the hardened merkle tree built by NewMerkleHashHardened.
+// The input slice is unmodified. An error is returned if the index is out of
+// range.
+func NewMerkleProofHardened(hashes []Hash, index int) (MerkleProof, error) {
+	return newMerkleProof(hashes, index, hashMerkleLeafHardened, hashMerklePairHardened)
+}
+
+// VerifyMerkleProofHardened returns true if the MerkleProof proves that the
+// leaf Hash is a leaf of the hardened merkle tree with the given root Hash,
+// otherwise it returns false. Proofs for the hardened merkle tree cannot be
+// verified using VerifyMerkleProof, and vice versa.
+func VerifyMerkleProofHardened(root, leaf *Hash, proof *MerkleProof) bool {
+	return verifyMerkleProof(root, leaf, proof, hashMerkleLeafHardened, hashMerklePairHardened)
+}
+
+// hashMerkleLeafHardened returns SHA2-256(0x00 || leaf).
+func hashMerkleLeafHardened(leaf *Hash) Hash {
+	buf := [1 + SizeHintHash]byte{MerkleLeafPrefix}
+	copy(buf[1:], leaf[:])
+	return Hash(sha256.Sum256(buf[:]))
+}
+
+// hashMerklePairHardened returns SHA2-256(0x01 || left || right).
+func hashMerklePairHardened(left, right *Hash) Hash {
+	buf := [1 + 2*SizeHintHash]byte{MerkleNodePrefix}
+	copy(buf[1:], left[:])
+	copy(buf[1+SizeHintHash:], right[:])
+	return Hash(sha256.Sum256(buf[:]))
+}
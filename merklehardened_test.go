@@ -0,0 +1,163 @@
+package id_test
+
+import (
+	"crypto/sha256"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hardened merkle hashes", func() {
+	leafHash := func(leaf id.Hash) id.Hash {
+		return id.Hash(sha256.Sum256(append([]byte{0x00}, leaf[:]...)))
+	}
+
+	nodeHash := func(left, right id.Hash) id.Hash {
+		return id.Hash(sha256.Sum256(append(append([]byte{0x01}, left[:]...), right[:]...)))
+	}
+
+	Context("when computing the merkle hash of zero hashes", func() {
+		It("should return the empty hash", func() {
+			Expect(id.NewMerkleHashHardened([]id.Hash{})).To(Equal(id.Hash{}))
+		})
+	})
+
+	Context("when computing the merkle hash of one hash", func() {
+		It("should return the leaf hash", func() {
+			hashes := randomHashes(1)
+			Expect(id.NewMerkleHashHardened(hashes)).To(Equal(leafHash(hashes[0])))
+		})
+	})
+
+	Context("when computing the merkle hash of three hashes", func() {
+		It("should prefix leaves and nodes", func() {
+			hashes := randomHashes(3)
+			expected := nodeHash(leafHash(hashes[0]), nodeHash(leafHash(hashes[1]), leafHash(hashes[2])))
+			Expect(id.NewMerkleHashHardened(hashes)).To(Equal(expected))
+		})
+	})
+
+	Context("when computing the merkle hash of many hashes", func() {
+		It("should not modify the input", func() {
+			f := func(n uint) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				copied := make([]id.Hash, len(hashes))
+				copy(copied, hashes)
+				id.NewMerkleHashHardened(hashes)
+				Expect(hashes).To(Equal(copied))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not equal the unhardened merkle hash", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				Expect(id.NewMerkleHashHardened(hashes)).ToNot(Equal(id.NewMerkleHash(hashes)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not confuse an internal node with a leaf", func() {
+			hashes := randomHashes(4)
+			root := id.NewMerkleHashHardened(hashes)
+			// Leaves that are the pre-images of the internal nodes of the
+			// unhardened merkle tree must not produce the same root.
+			left := nodeHash(leafHash(hashes[0]), leafHash(hashes[1]))
+			right := nodeHash(leafHash(hashes[2]), leafHash(hashes[3]))
+			Expect(id.NewMerkleHashHardened([]id.Hash{left, right})).ToNot(Equal(root))
+		})
+	})
+
+	Context("when using signatories", func() {
+		It("should equal the hash equivalent", func() {
+			f := func(n uint) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				signatories := make([]id.Signatory, n)
+				for i := range signatories {
+					signatories[i] = id.Signatory(hashes[i])
+				}
+				Expect(id.NewMerkleHashFromSignatoriesHardened(signatories)).To(Equal(id.NewMerkleHashHardened(hashes)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when proving a random leaf", func() {
+		It("should verify against the hardened merkle hash", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHashHardened(hashes)
+				proof, err := id.NewMerkleProofHardened(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleProofHardened(&root, &hashes[i], &proof)).To(BeTrue())
+				Expect(id.VerifyMerkleProof(&root, &hashes[i], &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a different leaf", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 2
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHashHardened(hashes)
+				proof, err := id.NewMerkleProofHardened(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				other := hashes[(i+1)%n]
+				Expect(id.VerifyMerkleProofHardened(&root, &other, &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a proof with bits set beyond the path", func() {
+			f := func(n, i uint, bit uint8) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHashHardened(hashes)
+				proof, err := id.NewMerkleProofHardened(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				unused := uint(len(proof.Path)) + uint(bit)%(64-uint(len(proof.Path)))
+				proof.Bits |= 1 << unused
+				Expect(id.VerifyMerkleProofHardened(&root, &hashes[i], &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify an unhardened proof", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleProof(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleProofHardened(&root, &hashes[i], &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when proving an index that is out of range", func() {
+		It("should return an error", func() {
+			hashes := randomHashes(10)
+			_, err := id.NewMerkleProofHardened(hashes, 10)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,86 @@
+package id
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// NewMerkleHashKeccak returns the root hash of the Keccak-256 merkle tree that
+// uses the hashes as leaves. The tree can be verified on-chain by the
+// OpenZeppelin MerkleProof library: internal nodes are hashed as the
+// Keccak-256 hash of the concatenation of their children, sorted in ascending
+// order, so proofs do not need to encode whether a sibling is on the left or
+// the right. The input slice is unmodified.
+//
+// The tree uses the same layout as the OpenZeppelin merkle-tree library: the
+// nodes are stored as a complete binary tree in an array of 2n-1 nodes, where
+// the children of the i-th node are the (2i+1)-th and (2i+2)-th nodes, and the
+// leaves are stored at the end of the array in reverse order. The OpenZeppelin
+// library sorts the leaves by default; to produce the same root hash, the
+// hashes must be sorted before calling this function.
+//
+// The hashes are used as the leaves as given, which is the same as the
+// SimpleMerkleTree of the OpenZeppelin library. Its StandardMerkleTree
+// double-hashes the ABI encoding of each value to produce the leaves, so those
+// leaves must be computed before calling this function.
+func NewMerkleHashKeccak(hashes []Hash) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+	return newMerkleTreeKeccak(hashes)[0]
+}
+
+// NewMerkleProofKeccak returns the proof that the hash at the given index is a
+// leaf of the Keccak-256 merkle tree built by NewMerkleHashKeccak. The proof is
+// the list of sibling hashes from the leaf to the root, and can be passed
+// directly to MerkleProof.verify in Solidity. The input slice is unmodified.
+// An error is returned if the index is out of range.
+func NewMerkleProofKeccak(hashes []Hash, index int) ([]Hash, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, fmt.Errorf("expected index<%v, got index=%v", len(hashes), index)
+	}
+	tree := newMerkleTreeKeccak(hashes)
+	proof := []Hash{}
+	for i := len(tree) - 1 - index; i > 0; i = (i - 1) / 2 {
+		// The sibling of an odd (left) node is to its right, and the sibling
+		// of an even (right) node is to its left.
+		proof = append(proof, tree[i-1+2*(i&1)])
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProofKeccak returns true if the proof proves that the leaf Hash
+// is a leaf of the Keccak-256 merkle tree with the given root Hash, otherwise
+// it returns false. This is the same as MerkleProof.verify in Solidity.
+func VerifyMerkleProofKeccak(root, leaf *Hash, proof []Hash) bool {
+	hash := *leaf
+	for i := range proof {
+		hash = hashMerklePairKeccak(&hash, &proof[i])
+	}
+	return hash.Equal(root)
+}
+
+// newMerkleTreeKeccak returns all of the nodes of the Keccak-256 merkle tree,
+// where the root is the first node. It assumes that there is at least one
+// hash.
+func newMerkleTreeKeccak(hashes []Hash) []Hash {
+	tree := make([]Hash, 2*len(hashes)-1)
+	for i := range hashes {
+		tree[len(tree)-1-i] = hashes[i]
+	}
+	for i := len(tree) - 1 - len(hashes); i >= 0; i-- {
+		tree[i] = hashMerklePairKeccak(&tree[2*i+1], &tree[2*i+2])
+	}
+	return tree
+}
+
+// hashMerklePairKeccak returns the Keccak-256 hash of the concatenation of two
+// hashes, sorted in ascending order.
+func hashMerklePairKeccak(a, b *Hash) Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return Hash(crypto.Keccak256Hash(a[:], b[:]))
+}
@@ -0,0 +1,147 @@
+package id_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keccak merkle hashes", func() {
+	fromHex := func(str string) id.Hash {
+		data, err := hex.DecodeString(str)
+		Expect(err).ToNot(HaveOccurred())
+		hash := id.Hash{}
+		copy(hash[:], data)
+		return hash
+	}
+
+	// processProof is a direct port of MerkleProof.processProof from the
+	// OpenZeppelin Solidity library.
+	processProof := func(proof []id.Hash, leaf id.Hash) id.Hash {
+		computedHash := leaf
+		for _, proofElement := range proof {
+			if bytes.Compare(computedHash[:], proofElement[:]) <= 0 {
+				computedHash = id.Hash(crypto.Keccak256Hash(computedHash[:], proofElement[:]))
+			} else {
+				computedHash = id.Hash(crypto.Keccak256Hash(proofElement[:], computedHash[:]))
+			}
+		}
+		return computedHash
+	}
+
+	Context("when computing known test vectors", func() {
+		It("should return the known zero hashes", func() {
+			// The zero hashes of Keccak-256 merkle trees, as used by many
+			// Solidity contracts. Sorting does not affect equal pairs, so these
+			// check the hashing, but not the layout.
+			zero := id.Hash{}
+			Expect(id.NewMerkleHashKeccak([]id.Hash{zero})).To(Equal(zero))
+			Expect(id.NewMerkleHashKeccak([]id.Hash{zero, zero})).To(Equal(fromHex("ad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5")))
+			Expect(id.NewMerkleHashKeccak([]id.Hash{zero, zero, zero, zero})).To(Equal(fromHex("b4c11951957c6f8f642c4af61cd6b24640fec6dc7fc607ee8206a99e92410d30")))
+		})
+
+		It("should sort pairs before hashing", func() {
+			a := fromHex("290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563")
+			b := fromHex("ad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5")
+			expected := id.Hash(crypto.Keccak256Hash(a[:], b[:]))
+			Expect(id.NewMerkleHashKeccak([]id.Hash{a, b})).To(Equal(expected))
+			Expect(id.NewMerkleHashKeccak([]id.Hash{b, a})).To(Equal(expected))
+		})
+
+		It("should return the root and proofs of the OpenZeppelin merkle-tree library", func() {
+			// The expected values are those of SimpleMerkleTree from the
+			// @openzeppelin/merkle-tree library, version 1.0.7, for the leaves
+			// keccak256("a"), keccak256("b"), keccak256("c"), keccak256("d"),
+			// and keccak256("e"), in that order, with the default options
+			// (which sort the leaves). They have not yet been checked against
+			// the library itself: they were computed by an independent port of
+			// its makeMerkleTree and getProof functions (src/core.ts), and must
+			// be confirmed by running the library with:
+			//
+			//  npm install @openzeppelin/merkle-tree@1.0.7 ethers@6
+			//
+			//  const { SimpleMerkleTree } = require("@openzeppelin/merkle-tree");
+			//  const { keccak256, toUtf8Bytes } = require("ethers");
+			//  const leaves = ["a", "b", "c", "d", "e"].map((s) => keccak256(toUtf8Bytes(s)));
+			//  const tree = SimpleMerkleTree.of(leaves);
+			//  console.log(tree.root, tree.getProof(2), tree.getProof(3));
+			//
+			// Sorting puts the leaves "c" and "d" first and last, so their
+			// proofs are the proofs of the sorted indices 0 and 4.
+			leaves := make([]id.Hash, 0, 5)
+			for _, s := range []string{"a", "b", "c", "d", "e"} {
+				leaves = append(leaves, id.Hash(crypto.Keccak256Hash([]byte(s))))
+			}
+			Expect(leaves[2]).To(Equal(fromHex("0b42b6393c1f53060fe3ddbfcd7aadcca894465a5a438f69c87d790b2299b9b2")))
+
+			sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i][:], leaves[j][:]) < 0 })
+			root := id.NewMerkleHashKeccak(leaves)
+			Expect(root).To(Equal(fromHex("724af1d1cac94553f82da5f12902c07f561d0f417038ab9411985137a90b2a38")))
+			proof, err := id.NewMerkleProofKeccak(leaves, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proof).To(Equal([]id.Hash{
+				fromHex("3ac225168df54212a25c1c01fd35bebfea408fdac2e31ddd6f80a4bbf9a5f1cb"),
+				fromHex("f1918e8562236eb17adc8502332f4c9c82bc14e19bfc0aa10ab674ff75b3d2f3"),
+				fromHex("434d51cfeb80272378f4c3a8fd2824561c2cad9fce556ea600d46f20550976a6"),
+			}))
+			proof, err = id.NewMerkleProofKeccak(leaves, 4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proof).To(Equal([]id.Hash{
+				fromHex("7dea550f679f3caab547cbbc5ee1a4c978c8c039b572ba00af1baa6481b88360"),
+				fromHex("434d51cfeb80272378f4c3a8fd2824561c2cad9fce556ea600d46f20550976a6"),
+			}))
+		})
+	})
+
+	Context("when proving a random leaf", func() {
+		It("should verify using the OpenZeppelin verifier", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+				root := id.NewMerkleHashKeccak(hashes)
+				proof, err := id.NewMerkleProofKeccak(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processProof(proof, hashes[i])).To(Equal(root))
+				Expect(id.VerifyMerkleProofKeccak(&root, &hashes[i], proof)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a different leaf", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 2
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHashKeccak(hashes)
+				proof, err := id.NewMerkleProofKeccak(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleProofKeccak(&root, &hashes[(i+1)%n], proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the index is out of range", func() {
+			_, err := id.NewMerkleProofKeccak(randomHashes(10), 10)
+			Expect(err).To(HaveOccurred())
+			_, err = id.NewMerkleProofKeccak(nil, 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when computing the merkle hash of zero hashes", func() {
+		It("should return the empty hash", func() {
+			Expect(id.NewMerkleHashKeccak(nil)).To(Equal(id.Hash{}))
+		})
+	})
+})
@@ -0,0 +1,232 @@
+package id
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/renproject/surge"
+)
+
+// A MerkleMultiProof proves that a set of hashes are leaves of the merkle tree
+// built by NewMerkleHash. Unlike a set of MerkleProofs, sibling hashes that
+// are shared between leaves, or that can be computed from other proven leaves,
+// are only included once (or not at all). NumLeaves is the number of leaves in
+// the merkle tree, and is needed to recover the shape of the tree. Indices are
+// the strictly increasing indices of the proven leaves. Path stores the
+// sibling hashes that are needed to recompute the root hash, in the order in
+// which they are consumed when recomputing the tree level by level, from
+// left-to-right.
+type MerkleMultiProof struct {
+	NumLeaves uint64
+	Indices   []uint64
+	Path      []Hash
+}
+
+// NewMerkleMultiProof returns the MerkleMultiProof that the hashes at the
+// given indices are leaves of the merkle tree built by NewMerkleHash. The
+// indices do not need to be sorted, and duplicates are ignored. The input
+// slices are unmodified. An error is returned if there are no indices, or if
+// any index is out of range.
+func NewMerkleMultiProof(hashes []Hash, indices []int) (MerkleMultiProof, error) {
+	if len(indices) == 0 {
+		return MerkleMultiProof{}, fmt.Errorf("expected len>0, got len=0")
+	}
+	sorted := make([]int, len(indices))
+	copy(sorted, indices)
+	sort.Ints(sorted)
+
+	proof := MerkleMultiProof{
+		NumLeaves: uint64(len(hashes)),
+		Indices:   make([]uint64, 0, len(sorted)),
+	}
+	for i, index := range sorted {
+		if index < 0 || index >= len(hashes) {
+			return MerkleMultiProof{}, fmt.Errorf("expected index<%v, got index=%v", len(hashes), index)
+		}
+		if i > 0 && sorted[i-1] == index {
+			continue
+		}
+		proof.Indices = append(proof.Indices, uint64(index))
+	}
+
+	level := make([]Hash, len(hashes))
+	copy(level, hashes)
+	known := make([]int, len(proof.Indices))
+	for i := range known {
+		known[i] = int(proof.Indices[i])
+	}
+	for l := len(level) / 2; l >= 1; l = len(level) / 2 {
+		b := len(level) & 1
+		parents := known[:0]
+		for i := 0; i < len(known); i++ {
+			index := known[i]
+			if b == 1 && index == 0 {
+				parents = append(parents, 0)
+				continue
+			}
+			j := index - b
+			if j&1 == 0 {
+				if i+1 < len(known) && known[i+1] == index+1 {
+					i++
+				} else {
+					proof.Path = append(proof.Path, level[index+1])
+				}
+			} else {
+				proof.Path = append(proof.Path, level[index-1])
+			}
+			parents = append(parents, b+j/2)
+		}
+		known = parents
+
+		for i := 0; i < l; i++ {
+			level[b+i] = hashMerklePair(&level[b+i*2], &level[b+i*2+1])
+		}
+		level = level[:b+l]
+	}
+	return proof, nil
+}
+
+// VerifyMerkleMultiProof returns true if the MerkleMultiProof proves that the
+// leaf hashes are leaves of the merkle tree with the given root Hash,
+// otherwise it returns false. The i-th leaf Hash must be the leaf at the i-th
+// index of the MerkleMultiProof.
+func VerifyMerkleMultiProof(root *Hash, leaves []Hash, proof *MerkleMultiProof) bool {
+	if len(leaves) == 0 || len(leaves) != len(proof.Indices) {
+		return false
+	}
+	for i, index := range proof.Indices {
+		if index >= proof.NumLeaves || (i > 0 && proof.Indices[i-1] >= index) {
+			return false
+		}
+	}
+
+	type node struct {
+		index uint64
+		hash  Hash
+	}
+	known := make([]node, len(leaves))
+	for i := range known {
+		known[i] = node{index: proof.Indices[i], hash: leaves[i]}
+	}
+	path := proof.Path
+	for n := proof.NumLeaves; n > 1; n = n&1 + n/2 {
+		b := n & 1
+		parents := known[:0]
+		for i := 0; i < len(known); i++ {
+			index := known[i].index
+			if b == 1 && index == 0 {
+				parents = append(parents, known[i])
+				continue
+			}
+			j := index - b
+			hash := Hash{}
+			if j&1 == 0 {
+				if i+1 < len(known) && known[i+1].index == index+1 {
+					hash = hashMerklePair(&known[i].hash, &known[i+1].hash)
+					i++
+				} else {
+					if len(path) == 0 {
+						return false
+					}
+					hash = hashMerklePair(&known[i].hash, &path[0])
+					path = path[1:]
+				}
+			} else {
+				if len(path) == 0 {
+					return false
+				}
+				hash = hashMerklePair(&path[0], &known[i].hash)
+				path = path[1:]
+			}
+			parents = append(parents, node{index: b + j/2, hash: hash})
+		}
+		known = parents
+	}
+	return len(path) == 0 && known[0].hash.Equal(root)
+}
+
+// Equal compares one MerkleMultiProof with another. If they are equal, then it
+// returns true, otherwise it returns false.
+func (proof MerkleMultiProof) Equal(other *MerkleMultiProof) bool {
+	if proof.NumLeaves != other.NumLeaves || len(proof.Indices) != len(other.Indices) || len(proof.Path) != len(other.Path) {
+		return false
+	}
+	for i := range proof.Indices {
+		if proof.Indices[i] != other.Indices[i] {
+			return false
+		}
+	}
+	for i := range proof.Path {
+		if !proof.Path[i].Equal(&other.Path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a
+// MerkleMultiProof in binary.
+func (proof MerkleMultiProof) SizeHint() int {
+	return surge.SizeHintU64 +
+		surge.SizeHintU16 + len(proof.Indices)*surge.SizeHintU64 +
+		surge.SizeHintU16 + len(proof.Path)*SizeHintHash
+}
+
+// Marshal into binary.
+func (proof MerkleMultiProof) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if len(proof.Indices) > math.MaxUint16 {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", math.MaxUint16, len(proof.Indices))
+	}
+	if len(proof.Path) > math.MaxUint16 {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", math.MaxUint16, len(proof.Path))
+	}
+	buf, rem, err := surge.MarshalU64(proof.NumLeaves, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.Marshal(proof.Indices, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(proof.Path, buf, rem)
+}
+
+// Unmarshal from binary.
+func (proof *MerkleMultiProof) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.UnmarshalU64(&proof.NumLeaves, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.Unmarshal(&proof.Indices, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	return surge.Unmarshal(&proof.Path, buf, rem)
+}
+
+// MarshalJSON implements the JSON marshaler interface for the
+// MerkleMultiProof type. It is represented as an unpadded base64 string of its
+// binary representation.
+func (proof MerkleMultiProof) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(proof)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the
+// MerkleMultiProof type. It assumes that it has been represented as an
+// unpadded base64 string of its binary representation.
+func (proof *MerkleMultiProof) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(proof, decoded)
+}
@@ -0,0 +1,230 @@
+package id_test
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	mrand "math/rand"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merkle multi-proofs", func() {
+	randomIndices := func(n int) []int {
+		indices := make([]int, mrand.Intn(n)+1)
+		for i := range indices {
+			indices[i] = mrand.Intn(n)
+		}
+		return indices
+	}
+
+	leavesOf := func(hashes []id.Hash, proof id.MerkleMultiProof) []id.Hash {
+		leaves := make([]id.Hash, len(proof.Indices))
+		for i, index := range proof.Indices {
+			leaves[i] = hashes[index]
+		}
+		return leaves
+	}
+
+	Context("when proving a random subset of leaves", func() {
+		It("should verify against the merkle hash", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleMultiProof(hashes, randomIndices(int(n)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleMultiProof(&root, leavesOf(hashes, proof), &proof)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should verify each proven leaf against a brute-force recomputation of the root", func() {
+			f := func(n uint) bool {
+				n = n%200 + 1
+				hashes := randomHashes(int(n))
+				proof, err := id.NewMerkleMultiProof(hashes, randomIndices(int(n)))
+				Expect(err).ToNot(HaveOccurred())
+				for i, index := range proof.Indices {
+					// Replacing a proven leaf does not change any of the
+					// sibling hashes in the proof, so the proof must verify
+					// the replaced leaf against the recomputed root.
+					modified := make([]id.Hash, len(hashes))
+					copy(modified, hashes)
+					rand.Read(modified[index][:])
+					root := id.NewMerkleHash(modified)
+					leaves := leavesOf(hashes, proof)
+					Expect(id.VerifyMerkleMultiProof(&root, leaves, &proof)).To(BeFalse())
+					leaves[i] = modified[index]
+					Expect(id.VerifyMerkleMultiProof(&root, leaves, &proof)).To(BeTrue())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should be no larger than independent proofs", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				proof, err := id.NewMerkleMultiProof(hashes, randomIndices(int(n)))
+				Expect(err).ToNot(HaveOccurred())
+				pathLen := 0
+				for _, index := range proof.Indices {
+					single, err := id.NewMerkleProof(hashes, int(index))
+					Expect(err).ToNot(HaveOccurred())
+					pathLen += len(single.Path)
+				}
+				Expect(len(proof.Path)).To(BeNumerically("<=", pathLen))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when proving all leaves", func() {
+		It("should return an empty path", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				indices := make([]int, n)
+				for i := range indices {
+					indices[i] = i
+				}
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleMultiProof(hashes, indices)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Path).To(BeEmpty())
+				Expect(id.VerifyMerkleMultiProof(&root, hashes, &proof)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying a malformed proof", func() {
+		It("should return false", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 2
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleMultiProof(hashes, []int{0})
+				Expect(err).ToNot(HaveOccurred())
+				leaves := leavesOf(hashes, proof)
+
+				extraPath := proof
+				extraPath.Path = append(extraPath.Path, id.Hash{})
+				Expect(id.VerifyMerkleMultiProof(&root, leaves, &extraPath)).To(BeFalse())
+
+				shortPath := proof
+				shortPath.Path = shortPath.Path[1:]
+				Expect(id.VerifyMerkleMultiProof(&root, leaves, &shortPath)).To(BeFalse())
+
+				wrongNumLeaves := proof
+				wrongNumLeaves.NumLeaves = 0
+				Expect(id.VerifyMerkleMultiProof(&root, leaves, &wrongNumLeaves)).To(BeFalse())
+
+				Expect(id.VerifyMerkleMultiProof(&root, append(leaves, id.Hash{}), &proof)).To(BeFalse())
+				Expect(id.VerifyMerkleMultiProof(&root, nil, &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return false for unsorted indices", func() {
+			hashes := randomHashes(8)
+			root := id.NewMerkleHash(hashes)
+			proof, err := id.NewMerkleMultiProof(hashes, []int{5, 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proof.Indices).To(Equal([]uint64{2, 5}))
+			leaves := []id.Hash{hashes[5], hashes[2]}
+			proof.Indices = []uint64{5, 2}
+			Expect(id.VerifyMerkleMultiProof(&root, leaves, &proof)).To(BeFalse())
+		})
+	})
+
+	Context("when proving an index that is out of range", func() {
+		It("should return an error", func() {
+			f := func(n uint) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				_, err := id.NewMerkleMultiProof(hashes, []int{int(n)})
+				Expect(err).To(HaveOccurred())
+				_, err = id.NewMerkleMultiProof(hashes, []int{-1})
+				Expect(err).To(HaveOccurred())
+				_, err = id.NewMerkleMultiProof(hashes, []int{})
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				proof, err := id.NewMerkleMultiProof(randomHashes(int(n)), randomIndices(int(n)))
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := surge.ToBinary(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleMultiProof{}
+				err = surge.FromBinary(&unmarshaled, marshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling random bytes using binary", func() {
+		It("should equal return an error", func() {
+			f := func(data []byte) bool {
+				if len(data) >= 12 {
+					return true
+				}
+				unmarshaled := id.MerkleMultiProof{}
+				err := surge.FromBinary(&unmarshaled, data)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using JSON", func() {
+		It("should equal itself", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				proof, err := id.NewMerkleMultiProof(randomHashes(int(n)), randomIndices(int(n)))
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := json.Marshal(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleMultiProof{}
+				err = json.Unmarshal(marshaled, &unmarshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling random bytes using JSON", func() {
+		It("should equal return an error", func() {
+			f := func(data []byte) bool {
+				unmarshaled := id.MerkleMultiProof{}
+				err := unmarshaled.UnmarshalJSON(data)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
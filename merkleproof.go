@@ -0,0 +1,226 @@
+package id
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/renproject/surge"
+)
+
+// MaxMerkleProofPathLen is the maximum number of sibling hashes that can be in
+// a MerkleProof. Every sibling hash corresponds to one level of the merkle
+// tree, so this is enough for trees with up to 2^64 leaves.
+const MaxMerkleProofPathLen = 64
+
+// A MerkleProof proves that a Hash is a leaf of the merkle tree built by
+// NewMerkleHash (or NewMerkleHashHardened). The Path stores the sibling hashes
+// that are needed to recompute the root hash, starting with the sibling of the
+// leaf and ending with the sibling of the child of the root. Levels at which
+// the node has no sibling (because it is the odd hash trailing at the front)
+// are skipped. The i-th bit of Bits is set if, and only if, the i-th sibling is
+// on the left.
+type MerkleProof struct {
+	Path []Hash
+	Bits uint64
+}
+
+// NewMerkleProof returns the MerkleProof that the hash at the given index is a
+// leaf of the merkle tree built by NewMerkleHash. The input slice is
+// unmodified. An error is returned if the index is out of range.
+func NewMerkleProof(hashes []Hash, index int) (MerkleProof, error) {
+	return newMerkleProof(hashes, index, hashMerkleLeafIdentity, hashMerklePair)
+}
+
+// VerifyMerkleProof returns true if the MerkleProof proves that the leaf Hash
+// is a leaf of the merkle tree with the given root Hash, otherwise it returns
+// false. A MerkleProof that sets any bit of Bits that does not correspond to a
+// sibling hash in the Path is rejected, so that every valid MerkleProof has
+// exactly one representation.
+func VerifyMerkleProof(root, leaf *Hash, proof *MerkleProof) bool {
+	return verifyMerkleProof(root, leaf, proof, hashMerkleLeafIdentity, hashMerklePair)
+}
+
+func newMerkleProof(hashes []Hash, index int, hashLeaf func(*Hash) Hash, hashNode func(*Hash, *Hash) Hash) (MerkleProof, error) {
+	if index < 0 || index >= len(hashes) {
+		return MerkleProof{}, fmt.Errorf("expected index<%v, got index=%v", len(hashes), index)
+	}
+	level := make([]Hash, len(hashes))
+	for i := range hashes {
+		level[i] = hashLeaf(&hashes[i])
+	}
+
+	proof := MerkleProof{}
+	for l := len(level) / 2; l >= 1; l = len(level) / 2 {
+		b := len(level) & 1
+		// The odd hash at the front has no sibling, and is carried up to the
+		// next level unchanged.
+		if b == 0 || index != 0 {
+			i := index - b
+			if i&1 == 0 {
+				proof.Path = append(proof.Path, level[index+1])
+			} else {
+				proof.Bits |= 1 << uint(len(proof.Path))
+				proof.Path = append(proof.Path, level[index-1])
+			}
+			index = b + i/2
+		}
+		for i := 0; i < l; i++ {
+			level[b+i] = hashNode(&level[b+i*2], &level[b+i*2+1])
+		}
+		level = level[:b+l]
+	}
+	return proof, nil
+}
+
+// newMerkleHashAndProofs returns the root hash of the merkle tree built by
+// NewMerkleHash, and the MerkleProof for every leaf. Every level of the merkle
+// tree is kept in memory, so that the proofs can be generated without
+// rehashing the tree for every leaf.
+func newMerkleHashAndProofs(hashes []Hash) (Hash, []MerkleProof) {
+	proofs := make([]MerkleProof, len(hashes))
+	if len(hashes) == 0 {
+		return Hash{}, proofs
+	}
+	levels := [][]Hash{hashes}
+	for level := hashes; len(level) > 1; level = levels[len(levels)-1] {
+		b := len(level) & 1
+		l := len(level) / 2
+		next := make([]Hash, b+l)
+		if b == 1 {
+			next[0] = level[0]
+		}
+		for i := 0; i < l; i++ {
+			next[b+i] = hashMerklePair(&level[b+i*2], &level[b+i*2+1])
+		}
+		levels = append(levels, next)
+	}
+	for j := range proofs {
+		index := j
+		for h := 0; h < len(levels)-1; h++ {
+			level := levels[h]
+			b := len(level) & 1
+			if b == 1 && index == 0 {
+				continue
+			}
+			i := index - b
+			if i&1 == 0 {
+				proofs[j].Path = append(proofs[j].Path, level[index+1])
+			} else {
+				proofs[j].Bits |= 1 << uint(len(proofs[j].Path))
+				proofs[j].Path = append(proofs[j].Path, level[index-1])
+			}
+			index = b + i/2
+		}
+	}
+	return levels[len(levels)-1][0], proofs
+}
+
+func verifyMerkleProof(root, leaf *Hash, proof *MerkleProof, hashLeaf func(*Hash) Hash, hashNode func(*Hash, *Hash) Hash) bool {
+	if len(proof.Path) > MaxMerkleProofPathLen {
+		return false
+	}
+	// Every bit of Bits is used when the Path has 64 sibling hashes.
+	if len(proof.Path) < 64 && proof.Bits>>uint(len(proof.Path)) != 0 {
+		return false
+	}
+	hash := hashLeaf(leaf)
+	for i := range proof.Path {
+		if proof.Bits&(1<<uint(i)) != 0 {
+			hash = hashNode(&proof.Path[i], &hash)
+		} else {
+			hash = hashNode(&hash, &proof.Path[i])
+		}
+	}
+	return hash.Equal(root)
+}
+
+// Equal compares one MerkleProof with another. If they are equal, then it
+// returns true, otherwise it returns false.
+func (proof MerkleProof) Equal(other *MerkleProof) bool {
+	if proof.Bits != other.Bits || len(proof.Path) != len(other.Path) {
+		return false
+	}
+	for i := range proof.Path {
+		if !proof.Path[i].Equal(&other.Path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a MerkleProof in
+// binary.
+func (proof MerkleProof) SizeHint() int {
+	return surge.SizeHintU64 + surge.SizeHintU16 + len(proof.Path)*SizeHintHash
+}
+
+// Marshal into binary.
+func (proof MerkleProof) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if len(proof.Path) > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, len(proof.Path))
+	}
+	buf, rem, err := surge.MarshalU64(proof.Bits, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(proof.Path, buf, rem)
+}
+
+// Unmarshal from binary.
+func (proof *MerkleProof) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.UnmarshalU64(&proof.Bits, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	pathLen := uint16(0)
+	if _, _, err := surge.UnmarshalU16(&pathLen, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if pathLen > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, pathLen)
+	}
+	return surge.Unmarshal(&proof.Path, buf, rem)
+}
+
+// MarshalJSON implements the JSON marshaler interface for the MerkleProof
+// type. It is represented as an unpadded base64 string of its binary
+// representation.
+func (proof MerkleProof) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(proof)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the MerkleProof
+// type. It assumes that it has been represented as an unpadded base64 string
+// of its binary representation.
+func (proof *MerkleProof) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(proof, decoded)
+}
+
+// hashMerklePair returns the SHA2 256-bit hash of the concatenation of two
+// hashes. This is how internal nodes are computed by NewMerkleHash.
+func hashMerklePair(left, right *Hash) Hash {
+	buf := [64]byte{}
+	copy(buf[:32], left[:])
+	copy(buf[32:], right[:])
+	return Hash(sha256.Sum256(buf[:]))
+}
+
+// hashMerkleLeafIdentity returns the leaf unchanged. This is how leaves are
+// computed by NewMerkleHash.
+func hashMerkleLeafIdentity(leaf *Hash) Hash {
+	return *leaf
+}
@@ -0,0 +1,242 @@
+package id_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// randomHashes returns n random hashes.
+func randomHashes(n int) []id.Hash {
+	hashes := make([]id.Hash, n)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	return hashes
+}
+
+var _ = Describe("Merkle proofs", func() {
+	Context("when proving every leaf", func() {
+		It("should verify against the merkle hash", func() {
+			for n := 1; n <= 64; n++ {
+				hashes := randomHashes(n)
+				root := id.NewMerkleHash(hashes)
+				for i := range hashes {
+					proof, err := id.NewMerkleProof(hashes, i)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(id.VerifyMerkleProof(&root, &hashes[i], &proof)).To(BeTrue())
+				}
+			}
+		})
+
+		It("should not modify the input", func() {
+			f := func(n uint) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				copied := make([]id.Hash, len(hashes))
+				copy(copied, hashes)
+				_, err := id.NewMerkleProof(hashes, int(n-1))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hashes).To(Equal(copied))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when proving a random leaf", func() {
+		It("should verify against the merkle hash", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleProof(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleProof(&root, &hashes[i], &proof)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a different leaf", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 2
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleProof(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				other := hashes[(i+1)%n]
+				Expect(id.VerifyMerkleProof(&root, &other, &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a modified path", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 2
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleProof(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				proof.Path[0][0] ^= 1
+				Expect(id.VerifyMerkleProof(&root, &hashes[i], &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify a proof with bits set beyond the path", func() {
+			f := func(n, i uint, bit uint8) bool {
+				n = n%1000 + 1
+				i = i % n
+				hashes := randomHashes(int(n))
+				root := id.NewMerkleHash(hashes)
+				proof, err := id.NewMerkleProof(hashes, int(i))
+				Expect(err).ToNot(HaveOccurred())
+				unused := uint(len(proof.Path)) + uint(bit)%(64-uint(len(proof.Path)))
+				proof.Bits |= 1 << unused
+				Expect(id.VerifyMerkleProof(&root, &hashes[i], &proof)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should verify a proof that uses every bit", func() {
+			leaf := randomHashes(1)[0]
+			proof := id.MerkleProof{Path: randomHashes(id.MaxMerkleProofPathLen), Bits: ^uint64(0)}
+			root := leaf
+			for i := range proof.Path {
+				root = id.NewMerkleHash([]id.Hash{proof.Path[i], root})
+			}
+			Expect(id.VerifyMerkleProof(&root, &leaf, &proof)).To(BeTrue())
+		})
+	})
+
+	Context("when proving a single leaf", func() {
+		It("should return an empty path", func() {
+			hashes := randomHashes(1)
+			proof, err := id.NewMerkleProof(hashes, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proof.Path).To(BeEmpty())
+		})
+	})
+
+	Context("when proving an index that is out of range", func() {
+		It("should return an error", func() {
+			f := func(n uint) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				_, err := id.NewMerkleProof(hashes, int(n))
+				Expect(err).To(HaveOccurred())
+				_, err = id.NewMerkleProof(hashes, -1)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				proof, err := id.NewMerkleProof(randomHashes(int(n)), int(i))
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := surge.ToBinary(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleProof{}
+				err = surge.FromBinary(&unmarshaled, marshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling random bytes using binary", func() {
+		It("should equal return an error", func() {
+			f := func(data []byte) bool {
+				if len(data) >= 10 {
+					return true
+				}
+				unmarshaled := id.MerkleProof{}
+				err := surge.FromBinary(&unmarshaled, data)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling a path that is too long", func() {
+		It("should return an error", func() {
+			proof := id.MerkleProof{Path: randomHashes(id.MaxMerkleProofPathLen + 1)}
+			_, err := surge.ToBinary(proof)
+			Expect(err).To(HaveOccurred())
+
+			proof.Path = proof.Path[:id.MaxMerkleProofPathLen]
+			marshaled, err := surge.ToBinary(proof)
+			Expect(err).ToNot(HaveOccurred())
+			marshaled[9]++
+			unmarshaled := id.MerkleProof{}
+			Expect(surge.FromBinary(&unmarshaled, marshaled)).ToNot(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using JSON", func() {
+		It("should equal itself", func() {
+			f := func(n, i uint) bool {
+				n = n%1000 + 1
+				i = i % n
+				proof, err := id.NewMerkleProof(randomHashes(int(n)), int(i))
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := json.Marshal(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleProof{}
+				err = json.Unmarshal(marshaled, &unmarshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal the base64 string of its binary representation", func() {
+			proof, err := id.NewMerkleProof(randomHashes(7), 3)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := proof.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			marshaled, err := surge.ToBinary(proof)
+			Expect(err).ToNot(HaveOccurred())
+			expected, err := json.Marshal(base64.RawURLEncoding.EncodeToString(marshaled))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Equal(got, expected)).To(BeTrue())
+		})
+	})
+
+	Context("when unmarshaling random bytes using JSON", func() {
+		It("should equal return an error", func() {
+			f := func(data []byte) bool {
+				unmarshaled := id.MerkleProof{}
+				err := unmarshaled.UnmarshalJSON(data)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
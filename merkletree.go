@@ -0,0 +1,214 @@
+package id
+
+import (
+	"fmt"
+
+	"github.com/renproject/surge"
+)
+
+// A MerkleTree is a merkle tree with all of its levels cached in memory. This
+// allows the root hash to be recomputed in O(log n) when a leaf is updated or
+// appended, and allows proofs to be generated without rehashing the tree.
+//
+// Unlike the merkle tree built by NewMerkleHash, the odd hash at each level
+// trails at the back, instead of the front. This means that appending a leaf
+// does not change the pairing of the existing hashes, and only the hashes on
+// the path from the new leaf to the root need to be recomputed. As a result,
+// the root hash is only the same as the one returned by NewMerkleHash when the
+// number of leaves is a power of two. Proofs generated by a MerkleTree are
+// verified by VerifyMerkleProof.
+type MerkleTree struct {
+	// levels[0] stores the leaves, and levels[len(levels)-1] stores the root.
+	levels [][]Hash
+}
+
+// NewMerkleTree returns a MerkleTree that uses the hashes as leaves. The input
+// slice is unmodified.
+func NewMerkleTree(hashes []Hash) *MerkleTree {
+	leaves := make([]Hash, len(hashes))
+	copy(leaves, hashes)
+	tree := &MerkleTree{levels: [][]Hash{leaves}}
+	for h := 0; len(tree.levels[h]) > 1; h++ {
+		level := tree.levels[h]
+		tree.levels = append(tree.levels, make([]Hash, (len(level)+1)/2))
+		for i := 0; i < len(level); i += 2 {
+			tree.rehash(h, i)
+		}
+	}
+	return tree
+}
+
+// Len returns the number of leaves in the MerkleTree.
+func (tree *MerkleTree) Len() int {
+	if len(tree.levels) == 0 {
+		return 0
+	}
+	return len(tree.levels[0])
+}
+
+// NumLevels returns the number of levels in the MerkleTree, including the
+// leaves and the root.
+func (tree *MerkleTree) NumLevels() int {
+	if tree.Len() == 0 {
+		return 0
+	}
+	return len(tree.levels)
+}
+
+// Level returns a copy of the hashes at the i-th level of the MerkleTree. The
+// 0-th level is the leaves, and the last level is the root. An error is
+// returned if the level is out of range.
+func (tree *MerkleTree) Level(i int) ([]Hash, error) {
+	if i < 0 || i >= tree.NumLevels() {
+		return nil, fmt.Errorf("expected level<%v, got level=%v", tree.NumLevels(), i)
+	}
+	level := make([]Hash, len(tree.levels[i]))
+	copy(level, tree.levels[i])
+	return level, nil
+}
+
+// Root returns the root hash of the MerkleTree.
+func (tree *MerkleTree) Root() Hash {
+	if tree.Len() == 0 {
+		return Hash{}
+	}
+	return tree.levels[len(tree.levels)-1][0]
+}
+
+// Update the leaf at the given index, and recompute the hashes on the path
+// from the leaf to the root. An error is returned if the index is out of
+// range.
+func (tree *MerkleTree) Update(index int, hash Hash) error {
+	if index < 0 || index >= tree.Len() {
+		return fmt.Errorf("expected index<%v, got index=%v", tree.Len(), index)
+	}
+	tree.levels[0][index] = hash
+	for h := 0; h < len(tree.levels)-1; h++ {
+		tree.rehash(h, index)
+		index /= 2
+	}
+	return nil
+}
+
+// Append a leaf to the MerkleTree, and recompute the hashes on the path from
+// the new leaf to the root. When the number of leaves becomes one more than a
+// power of two, a new root is added above the old root.
+func (tree *MerkleTree) Append(hash Hash) {
+	if len(tree.levels) == 0 {
+		tree.levels = [][]Hash{{}}
+	}
+	tree.levels[0] = append(tree.levels[0], hash)
+	index := len(tree.levels[0]) - 1
+	for h := 0; len(tree.levels[h]) > 1; h++ {
+		if h+1 == len(tree.levels) {
+			tree.levels = append(tree.levels, nil)
+		}
+		if index/2 == len(tree.levels[h+1]) {
+			tree.levels[h+1] = append(tree.levels[h+1], Hash{})
+		}
+		tree.rehash(h, index)
+		index /= 2
+	}
+}
+
+// Proof returns the MerkleProof that the leaf at the given index is a leaf of
+// the MerkleTree. An error is returned if the index is out of range.
+func (tree *MerkleTree) Proof(index int) (MerkleProof, error) {
+	if index < 0 || index >= tree.Len() {
+		return MerkleProof{}, fmt.Errorf("expected index<%v, got index=%v", tree.Len(), index)
+	}
+	proof := MerkleProof{}
+	for h := 0; h < len(tree.levels)-1; h++ {
+		level := tree.levels[h]
+		// The odd hash at the back has no sibling, and is carried up to the
+		// next level unchanged.
+		if index&1 == 1 {
+			proof.Bits |= 1 << uint(len(proof.Path))
+			proof.Path = append(proof.Path, level[index-1])
+		} else if index+1 < len(level) {
+			proof.Path = append(proof.Path, level[index+1])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// rehash the parent of the hash at the given index and level, from the hash
+// and its sibling.
+func (tree *MerkleTree) rehash(h, index int) {
+	level := tree.levels[h]
+	i := index &^ 1
+	if i+1 == len(level) {
+		tree.levels[h+1][i/2] = level[i]
+		return
+	}
+	tree.levels[h+1][i/2] = hashMerklePair(&level[i], &level[i+1])
+}
+
+// SizeHint returns the number of bytes required to represent the MerkleTree in
+// binary.
+func (tree MerkleTree) SizeHint() int {
+	sizeHint := surge.SizeHintU64
+	for h := range tree.levels {
+		sizeHint += len(tree.levels[h]) * SizeHintHash
+	}
+	return sizeHint
+}
+
+// Marshal into binary. The number of leaves is marshaled first, and then every
+// level, starting with the leaves. The number of hashes at each level is
+// implied by the number of leaves.
+func (tree MerkleTree) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.MarshalU64(uint64(tree.Len()), buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	for h := range tree.levels {
+		for i := range tree.levels[h] {
+			if buf, rem, err = tree.levels[h][i].Marshal(buf, rem); err != nil {
+				return buf, rem, err
+			}
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal from binary. The levels are not rehashed, so the MerkleTree must
+// only be unmarshaled from a trusted source.
+func (tree *MerkleTree) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	n := uint64(0)
+	buf, rem, err := surge.UnmarshalU64(&n, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	// Check the memory quota before allocating anything. There are less than
+	// 2n+log(n) hashes in total.
+	if n > uint64(rem/SizeHintHash) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	total := uint64(0)
+	for l := n; ; l = (l + 1) / 2 {
+		total += l
+		if l <= 1 {
+			break
+		}
+	}
+	if total > uint64(rem/SizeHintHash) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+
+	tree.levels = [][]Hash{}
+	for l := n; ; l = (l + 1) / 2 {
+		level := make([]Hash, l)
+		for i := range level {
+			if buf, rem, err = level[i].Unmarshal(buf, rem); err != nil {
+				return buf, rem, err
+			}
+		}
+		tree.levels = append(tree.levels, level)
+		if l <= 1 {
+			break
+		}
+	}
+	return buf, rem, nil
+}
@@ -0,0 +1,232 @@
+package id_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// carriedMerkleHash returns the root hash of the merkle tree that carries the
+// odd hash at the back of each level up to the next level.
+func carriedMerkleHash(hashes []id.Hash) id.Hash {
+	if len(hashes) == 0 {
+		return id.Hash{}
+	}
+	level := append([]id.Hash{}, hashes...)
+	for len(level) > 1 {
+		next := make([]id.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, id.NewMerkleHash(level[i:i+2]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+var _ = Describe("Merkle trees", func() {
+	Context("when creating a merkle tree", func() {
+		It("should carry the odd hash at each level up to the next level", func() {
+			f := func(n uint16) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				tree := id.NewMerkleTree(hashes)
+				Expect(tree.Len()).To(Equal(int(n)))
+				Expect(tree.Root()).To(Equal(carriedMerkleHash(hashes)))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should have the same root as the merkle hash for powers of two", func() {
+			for n := 1; n <= 1024; n *= 2 {
+				hashes := randomHashes(n)
+				Expect(id.NewMerkleTree(hashes).Root()).To(Equal(id.NewMerkleHash(hashes)))
+			}
+		})
+
+		It("should expose the leaves and the root as levels", func() {
+			f := func(n uint16) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				tree := id.NewMerkleTree(hashes)
+				leaves, err := tree.Level(0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(leaves).To(Equal(hashes))
+				root, err := tree.Level(tree.NumLevels() - 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(root).To(Equal([]id.Hash{tree.Root()}))
+				_, err = tree.Level(tree.NumLevels())
+				Expect(err).To(HaveOccurred())
+				_, err = tree.Level(-1)
+				Expect(err).To(HaveOccurred())
+
+				// Each level must be the merkle hash of the previous level,
+				// hashed one level up.
+				for i := 1; i < tree.NumLevels(); i++ {
+					prev, err := tree.Level(i - 1)
+					Expect(err).ToNot(HaveOccurred())
+					level, err := tree.Level(i)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(len(level)).To(Equal((len(prev) + 1) / 2))
+					Expect(carriedMerkleHash(level)).To(Equal(tree.Root()))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when updating leaves", func() {
+		It("should have the same root as a new merkle tree", func() {
+			f := func(n uint16) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				tree := id.NewMerkleTree(hashes)
+				for i := 0; i < 10; i++ {
+					index := mrand.Intn(int(n))
+					rand.Read(hashes[index][:])
+					Expect(tree.Update(index, hashes[index])).To(Succeed())
+					Expect(tree.Root()).To(Equal(carriedMerkleHash(hashes)))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the index is out of range", func() {
+			tree := id.NewMerkleTree(randomHashes(10))
+			Expect(tree.Update(10, id.Hash{})).ToNot(Succeed())
+			Expect(tree.Update(-1, id.Hash{})).ToNot(Succeed())
+		})
+	})
+
+	Context("when appending leaves", func() {
+		It("should have the same root as a new merkle tree after every append", func() {
+			hashes := randomHashes(300)
+			tree := id.NewMerkleTree(nil)
+			Expect(tree.Root()).To(Equal(id.Hash{}))
+			for i := range hashes {
+				tree.Append(hashes[i])
+				Expect(tree.Root()).To(Equal(id.NewMerkleTree(hashes[:i+1]).Root()))
+				Expect(tree.Root()).To(Equal(carriedMerkleHash(hashes[:i+1])))
+				Expect(tree.NumLevels()).To(Equal(id.NewMerkleTree(hashes[:i+1]).NumLevels()))
+			}
+		})
+	})
+
+	Context("when proving leaves", func() {
+		It("should verify against the root", func() {
+			f := func(n uint16) bool {
+				n = n%1000 + 1
+				hashes := randomHashes(int(n))
+				tree := id.NewMerkleTree(hashes)
+				root := tree.Root()
+				for i := 0; i < 10; i++ {
+					index := mrand.Intn(int(n))
+					proof, err := tree.Proof(index)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(id.VerifyMerkleProof(&root, &hashes[index], &proof)).To(BeTrue())
+					other := randomHashes(1)[0]
+					Expect(id.VerifyMerkleProof(&root, &other, &proof)).To(BeFalse())
+				}
+				_, err := tree.Proof(int(n))
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal the merkle proof for powers of two", func() {
+			for n := 1; n <= 1024; n *= 2 {
+				hashes := randomHashes(n)
+				tree := id.NewMerkleTree(hashes)
+				index := mrand.Intn(n)
+				proof, err := tree.Proof(index)
+				Expect(err).ToNot(HaveOccurred())
+				expected, err := id.NewMerkleProof(hashes, index)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof.Equal(&expected)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(n uint16) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				tree := id.NewMerkleTree(hashes)
+				marshaled, err := surge.ToBinary(tree)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleTree{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(unmarshaled.Len()).To(Equal(tree.Len()))
+				Expect(unmarshaled.NumLevels()).To(Equal(tree.NumLevels()))
+				Expect(unmarshaled.Root()).To(Equal(tree.Root()))
+
+				// The unmarshaled tree must continue to behave the same as
+				// the original tree.
+				next := randomHashes(1)[0]
+				tree.Append(next)
+				unmarshaled.Append(next)
+				Expect(unmarshaled.Root()).To(Equal(tree.Root()))
+				Expect(unmarshaled.Update(0, next)).To(Succeed())
+				Expect(tree.Update(0, next)).To(Succeed())
+				Expect(unmarshaled.Root()).To(Equal(tree.Root()))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error for truncated bytes", func() {
+			marshaled, err := surge.ToBinary(id.NewMerkleTree(randomHashes(10)))
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < len(marshaled); i++ {
+				unmarshaled := id.MerkleTree{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled[:i])).ToNot(Succeed())
+			}
+		})
+	})
+})
+
+func BenchmarkMerkleTreeUpdate(b *testing.B) {
+	hashes := make([]id.Hash, 1000000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	tree := id.NewMerkleTree(hashes)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tree.Update(i%len(hashes), hashes[i%len(hashes)])
+	}
+}
+
+func BenchmarkMerkleTreeAppend(b *testing.B) {
+	for _, n := range []int{1000, 1000000} {
+		b.Run(fmt.Sprintf("n=%v", n), func(b *testing.B) {
+			hashes := make([]id.Hash, n)
+			for i := range hashes {
+				rand.Read(hashes[i][:])
+			}
+			tree := id.NewMerkleTree(hashes)
+			b.ResetTimer()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tree.Append(hashes[i%len(hashes)])
+			}
+		})
+	}
+}
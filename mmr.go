@@ -0,0 +1,339 @@
+package id
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+
+	"github.com/renproject/surge"
+)
+
+// A MerkleMountainRange is an append-only accumulator of hashes. The leaves
+// are grouped, from left-to-right, into perfect merkle trees of strictly
+// decreasing height (one for every set bit in the number of leaves). The roots
+// of these merkle trees are called peaks. Appending a leaf merges peaks of the
+// same height, and the root hash is computed by "bagging" the peaks from
+// right-to-left. Internal nodes use the same hashing as NewMerkleHash. For
+// example, with eleven leaves there are three peaks (over eight, two, and one
+// leaves) and the root hash is H(peak8 || H(peak2 || peak1)).
+//
+// All nodes are kept in memory, so that proofs can be generated for any leaf
+// against the latest root hash.
+type MerkleMountainRange struct {
+	// levels[h] stores the roots of all perfect subtrees of height h, from
+	// left-to-right.
+	levels [][]Hash
+}
+
+// NewMerkleMountainRange returns an empty MerkleMountainRange.
+func NewMerkleMountainRange() *MerkleMountainRange {
+	return &MerkleMountainRange{}
+}
+
+// Len returns the number of leaves in the MerkleMountainRange.
+func (mmr *MerkleMountainRange) Len() uint64 {
+	if len(mmr.levels) == 0 {
+		return 0
+	}
+	return uint64(len(mmr.levels[0]))
+}
+
+// Append a leaf to the MerkleMountainRange. This requires O(log n) hashing in
+// the worst case, and O(1) hashing on average.
+func (mmr *MerkleMountainRange) Append(leaf Hash) {
+	hash := leaf
+	for h := 0; ; h++ {
+		if h == len(mmr.levels) {
+			mmr.levels = append(mmr.levels, []Hash{})
+		}
+		mmr.levels[h] = append(mmr.levels[h], hash)
+		if len(mmr.levels[h])&1 == 1 {
+			return
+		}
+		hash = hashMerklePair(&mmr.levels[h][len(mmr.levels[h])-2], &hash)
+	}
+}
+
+// Peaks returns the roots of the perfect merkle trees that make up the
+// MerkleMountainRange, from left-to-right (and so, from highest to lowest).
+func (mmr *MerkleMountainRange) Peaks() []Hash {
+	peaks := make([]Hash, 0, len(mmr.levels))
+	for h := len(mmr.levels) - 1; h >= 0; h-- {
+		if len(mmr.levels[h])&1 == 1 {
+			peaks = append(peaks, mmr.levels[h][len(mmr.levels[h])-1])
+		}
+	}
+	return peaks
+}
+
+// Root returns the root hash of the MerkleMountainRange. It is computed by
+// bagging the peaks from right-to-left. The root hash of an empty
+// MerkleMountainRange is the empty Hash.
+func (mmr *MerkleMountainRange) Root() Hash {
+	return bagMerkleMountainRangePeaks(mmr.Peaks())
+}
+
+// Prove returns the MerkleMountainRangeProof that the leaf at the given index
+// is in the MerkleMountainRange. The proof is against the current root hash.
+// An error is returned if the index is out of range.
+func (mmr *MerkleMountainRange) Prove(index uint64) (MerkleMountainRangeProof, error) {
+	n := mmr.Len()
+	if index >= n {
+		return MerkleMountainRangeProof{}, fmt.Errorf("expected index<%v, got index=%v", n, index)
+	}
+	proof := MerkleMountainRangeProof{NumLeaves: n, Index: index}
+
+	// Find the height of the peak that contains the leaf.
+	height := 0
+	offset := uint64(0)
+	for h := len(mmr.levels) - 1; h >= 0; h-- {
+		if n&(1<<uint(h)) == 0 {
+			continue
+		}
+		if index < offset+1<<uint(h) {
+			height = h
+			break
+		}
+		offset += 1 << uint(h)
+	}
+
+	i := index
+	for h := 0; h < height; h++ {
+		proof.Path = append(proof.Path, mmr.levels[h][i^1])
+		i >>= 1
+	}
+	for h := len(mmr.levels) - 1; h >= 0; h-- {
+		if h != height && len(mmr.levels[h])&1 == 1 {
+			proof.Peaks = append(proof.Peaks, mmr.levels[h][len(mmr.levels[h])-1])
+		}
+	}
+	return proof, nil
+}
+
+// SizeHint returns the number of bytes required to represent the
+// MerkleMountainRange in binary.
+func (mmr MerkleMountainRange) SizeHint() int {
+	sizeHint := surge.SizeHintU64
+	for h := range mmr.levels {
+		sizeHint += len(mmr.levels[h]) * SizeHintHash
+	}
+	return sizeHint
+}
+
+// Marshal into binary. The number of leaves is marshaled first, and then every
+// node, level by level. The number of nodes at each level is implied by the
+// number of leaves.
+func (mmr MerkleMountainRange) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.MarshalU64(mmr.Len(), buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	for h := range mmr.levels {
+		for i := range mmr.levels[h] {
+			if buf, rem, err = mmr.levels[h][i].Marshal(buf, rem); err != nil {
+				return buf, rem, err
+			}
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal from binary.
+func (mmr *MerkleMountainRange) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	n := uint64(0)
+	buf, rem, err := surge.UnmarshalU64(&n, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	// Check the memory quota before allocating anything. There are exactly
+	// 2n-popcount(n) nodes in total.
+	if n > uint64(rem/SizeHintHash) || 2*n-uint64(bits.OnesCount64(n)) > uint64(rem/SizeHintHash) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	mmr.levels = make([][]Hash, bits.Len64(n))
+	for h := range mmr.levels {
+		mmr.levels[h] = make([]Hash, n>>uint(h))
+		for i := range mmr.levels[h] {
+			if buf, rem, err = mmr.levels[h][i].Unmarshal(buf, rem); err != nil {
+				return buf, rem, err
+			}
+		}
+	}
+	return buf, rem, nil
+}
+
+// A MerkleMountainRangeProof proves that a Hash is a leaf of a
+// MerkleMountainRange. NumLeaves is the number of leaves in the
+// MerkleMountainRange, and is needed to recover the heights of the peaks. The
+// Path stores the sibling hashes that are needed to recompute the peak that
+// contains the leaf, starting with the sibling of the leaf. Peaks stores all
+// of the other peaks, from left-to-right.
+type MerkleMountainRangeProof struct {
+	NumLeaves uint64
+	Index     uint64
+	Path      []Hash
+	Peaks     []Hash
+}
+
+// VerifyMerkleMountainRangeProof returns true if the MerkleMountainRangeProof
+// proves that the leaf Hash is a leaf of the MerkleMountainRange with the
+// given root Hash, otherwise it returns false.
+func VerifyMerkleMountainRangeProof(root, leaf *Hash, proof *MerkleMountainRangeProof) bool {
+	if proof.Index >= proof.NumLeaves {
+		return false
+	}
+	if len(proof.Peaks) != bits.OnesCount64(proof.NumLeaves)-1 {
+		return false
+	}
+
+	// Find the height of the peak that contains the leaf, and the number of
+	// peaks to its left.
+	height := 0
+	offset := uint64(0)
+	numPeaksLeft := 0
+	for h := 63; h >= 0; h-- {
+		if proof.NumLeaves&(1<<uint(h)) == 0 {
+			continue
+		}
+		if proof.Index < offset+1<<uint(h) {
+			height = h
+			break
+		}
+		offset += 1 << uint(h)
+		numPeaksLeft++
+	}
+	if len(proof.Path) != height {
+		return false
+	}
+
+	hash := *leaf
+	i := proof.Index - offset
+	for h := range proof.Path {
+		if i&1 == 0 {
+			hash = hashMerklePair(&hash, &proof.Path[h])
+		} else {
+			hash = hashMerklePair(&proof.Path[h], &hash)
+		}
+		i >>= 1
+	}
+
+	peaks := make([]Hash, 0, len(proof.Peaks)+1)
+	peaks = append(peaks, proof.Peaks[:numPeaksLeft]...)
+	peaks = append(peaks, hash)
+	peaks = append(peaks, proof.Peaks[numPeaksLeft:]...)
+	bagged := bagMerkleMountainRangePeaks(peaks)
+	return bagged.Equal(root)
+}
+
+// Equal compares one MerkleMountainRangeProof with another. If they are equal,
+// then it returns true, otherwise it returns false.
+func (proof MerkleMountainRangeProof) Equal(other *MerkleMountainRangeProof) bool {
+	if proof.NumLeaves != other.NumLeaves || proof.Index != other.Index ||
+		len(proof.Path) != len(other.Path) || len(proof.Peaks) != len(other.Peaks) {
+		return false
+	}
+	for i := range proof.Path {
+		if !proof.Path[i].Equal(&other.Path[i]) {
+			return false
+		}
+	}
+	for i := range proof.Peaks {
+		if !proof.Peaks[i].Equal(&other.Peaks[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a
+// MerkleMountainRangeProof in binary.
+func (proof MerkleMountainRangeProof) SizeHint() int {
+	return 2*surge.SizeHintU64 +
+		surge.SizeHintU16 + len(proof.Path)*SizeHintHash +
+		surge.SizeHintU16 + len(proof.Peaks)*SizeHintHash
+}
+
+// Marshal into binary.
+func (proof MerkleMountainRangeProof) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if len(proof.Path) > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, len(proof.Path))
+	}
+	if len(proof.Peaks) > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, len(proof.Peaks))
+	}
+	buf, rem, err := surge.MarshalU64(proof.NumLeaves, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.MarshalU64(proof.Index, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.Marshal(proof.Path, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(proof.Peaks, buf, rem)
+}
+
+// Unmarshal from binary.
+func (proof *MerkleMountainRangeProof) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.UnmarshalU64(&proof.NumLeaves, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.UnmarshalU64(&proof.Index, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.Unmarshal(&proof.Path, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if len(proof.Path) > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, len(proof.Path))
+	}
+	if buf, rem, err = surge.Unmarshal(&proof.Peaks, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if len(proof.Peaks) > MaxMerkleProofPathLen {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", MaxMerkleProofPathLen, len(proof.Peaks))
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface for the
+// MerkleMountainRangeProof type. It is represented as an unpadded base64
+// string of its binary representation.
+func (proof MerkleMountainRangeProof) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(proof)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the
+// MerkleMountainRangeProof type. It assumes that it has been represented as an
+// unpadded base64 string of its binary representation.
+func (proof *MerkleMountainRangeProof) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(proof, decoded)
+}
+
+// bagMerkleMountainRangePeaks folds the peaks from right-to-left into a single
+// root hash.
+func bagMerkleMountainRangePeaks(peaks []Hash) Hash {
+	if len(peaks) == 0 {
+		return Hash{}
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = hashMerklePair(&peaks[i], &root)
+	}
+	return root
+}
@@ -0,0 +1,214 @@
+package id_test
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merkle mountain ranges", func() {
+	newMMR := func(hashes []id.Hash) *id.MerkleMountainRange {
+		mmr := id.NewMerkleMountainRange()
+		for _, hash := range hashes {
+			mmr.Append(hash)
+		}
+		return mmr
+	}
+
+	// bruteForceRoot splits the leaves into perfect subtrees of decreasing
+	// size, and bags them from right-to-left.
+	bruteForceRoot := func(hashes []id.Hash) id.Hash {
+		peaks := []id.Hash{}
+		for len(hashes) > 0 {
+			size := 1
+			for size*2 <= len(hashes) {
+				size *= 2
+			}
+			peaks = append(peaks, id.NewMerkleHash(hashes[:size]))
+			hashes = hashes[size:]
+		}
+		if len(peaks) == 0 {
+			return id.Hash{}
+		}
+		root := peaks[len(peaks)-1]
+		for i := len(peaks) - 2; i >= 0; i-- {
+			root = id.NewMerkleHash([]id.Hash{peaks[i], root})
+		}
+		return root
+	}
+
+	Context("when appending leaves", func() {
+		It("should equal the brute-force root after every append", func() {
+			hashes := randomHashes(300)
+			mmr := id.NewMerkleMountainRange()
+			Expect(mmr.Root()).To(Equal(id.Hash{}))
+			for i := range hashes {
+				mmr.Append(hashes[i])
+				Expect(mmr.Len()).To(Equal(uint64(i + 1)))
+				Expect(mmr.Root()).To(Equal(bruteForceRoot(hashes[:i+1])))
+			}
+		})
+
+		It("should have one peak for every set bit in the number of leaves", func() {
+			f := func(n uint16) bool {
+				mmr := newMMR(randomHashes(int(n)))
+				numPeaks := 0
+				for x := n; x > 0; x &= x - 1 {
+					numPeaks++
+				}
+				Expect(mmr.Peaks()).To(HaveLen(numPeaks))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should equal the merkle hash when the number of leaves is a power of two", func() {
+			for n := 1; n <= 1024; n *= 2 {
+				hashes := randomHashes(n)
+				Expect(newMMR(hashes).Root()).To(Equal(id.NewMerkleHash(hashes)))
+			}
+		})
+	})
+
+	Context("when proving leaves", func() {
+		It("should verify every leaf against the latest root", func() {
+			hashes := randomHashes(100)
+			mmr := id.NewMerkleMountainRange()
+			for n := range hashes {
+				mmr.Append(hashes[n])
+				root := mmr.Root()
+				for i := 0; i <= n; i++ {
+					proof, err := mmr.Prove(uint64(i))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[i], &proof)).To(BeTrue())
+				}
+			}
+		})
+
+		It("should not verify a different leaf or an old root", func() {
+			f := func(n, i uint16) bool {
+				n = n%1000 + 2
+				i = i % n
+				hashes := randomHashes(int(n))
+				oldRoot := newMMR(hashes[:n-1]).Root()
+				mmr := newMMR(hashes)
+				root := mmr.Root()
+				proof, err := mmr.Prove(uint64(i))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[i], &proof)).To(BeTrue())
+				Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[(i+1)%n], &proof)).To(BeFalse())
+				Expect(id.VerifyMerkleMountainRangeProof(&oldRoot, &hashes[i], &proof)).To(BeFalse())
+
+				malformed := proof
+				malformed.NumLeaves = uint64(i)
+				Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[i], &malformed)).To(BeFalse())
+				malformed = proof
+				malformed.Peaks = append(malformed.Peaks, id.Hash{})
+				Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[i], &malformed)).To(BeFalse())
+				malformed = proof
+				malformed.Index = uint64(n)
+				Expect(id.VerifyMerkleMountainRangeProof(&root, &hashes[i], &malformed)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the index is out of range", func() {
+			mmr := newMMR(randomHashes(10))
+			_, err := mmr.Prove(10)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(n uint16) bool {
+				n = n % 1000
+				hashes := randomHashes(int(n))
+				mmr := newMMR(hashes)
+				marshaled, err := surge.ToBinary(mmr)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleMountainRange{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(unmarshaled.Len()).To(Equal(mmr.Len()))
+				Expect(unmarshaled.Root()).To(Equal(mmr.Root()))
+
+				// Appending to the unmarshaled state must be the same as
+				// appending to the original state.
+				next := randomHashes(1)[0]
+				mmr.Append(next)
+				unmarshaled.Append(next)
+				Expect(unmarshaled.Root()).To(Equal(mmr.Root()))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error for truncated bytes", func() {
+			marshaled, err := surge.ToBinary(newMMR(randomHashes(10)))
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < len(marshaled); i++ {
+				unmarshaled := id.MerkleMountainRange{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled[:i])).ToNot(Succeed())
+			}
+		})
+	})
+
+	Context("when marshaling and then unmarshaling proofs", func() {
+		It("should equal itself", func() {
+			f := func(n, i uint16) bool {
+				n = n%1000 + 1
+				i = i % n
+				proof, err := newMMR(randomHashes(int(n))).Prove(uint64(i))
+				Expect(err).ToNot(HaveOccurred())
+
+				marshaled, err := surge.ToBinary(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.MerkleMountainRangeProof{}
+				Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+
+				marshaledJSON, err := json.Marshal(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled = id.MerkleMountainRangeProof{}
+				Expect(json.Unmarshal(marshaledJSON, &unmarshaled)).To(Succeed())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error for random bytes", func() {
+			f := func(data []byte) bool {
+				if len(data) >= 20 {
+					return true
+				}
+				unmarshaled := id.MerkleMountainRangeProof{}
+				Expect(surge.FromBinary(&unmarshaled, data)).ToNot(Succeed())
+				Expect(unmarshaled.UnmarshalJSON(data)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
+
+func BenchmarkMerkleMountainRangeAppend(b *testing.B) {
+	hashes := make([]id.Hash, b.N)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	mmr := id.NewMerkleMountainRange()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := range hashes {
+		mmr.Append(hashes[i])
+	}
+}
@@ -0,0 +1,216 @@
+package id
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/renproject/surge"
+)
+
+// A MultihashCode identifies the hashing function that was used to produce the
+// digest of a Multihash. The values are defined by the multiformats multicodec
+// table.
+type MultihashCode uint64
+
+// Multihash codes for the hashing functions that have a Hasher.
+const (
+	MultihashSHA256     = MultihashCode(0x12)
+	MultihashSHA3256    = MultihashCode(0x16)
+	MultihashKeccak256  = MultihashCode(0x1b)
+	MultihashBlake2b256 = MultihashCode(0xb220)
+)
+
+// Hasher returns the Hasher for the MultihashCode. An error is returned if the
+// MultihashCode is not known.
+func (code MultihashCode) Hasher() (Hasher, error) {
+	switch code {
+	case MultihashSHA256:
+		return SHA256Hasher, nil
+	case MultihashSHA3256:
+		return SHA3256Hasher, nil
+	case MultihashKeccak256:
+		return Keccak256Hasher, nil
+	case MultihashBlake2b256:
+		return Blake2b256Hasher, nil
+	default:
+		return nil, fmt.Errorf("unknown multihash code=%#x", uint64(code))
+	}
+}
+
+// A Multihash is a self-describing Hash. It stores the code of the hashing
+// function that produced the Hash alongside the Hash itself, so that the
+// hashing function can be changed without ambiguity. It is encoded as defined
+// by the multiformats multihash spec:
+//
+//  <varint code><varint length><digest>
+//
+// The length is always 32 bytes.
+type Multihash struct {
+	Code   MultihashCode
+	Digest Hash
+}
+
+// NewMultihash returns a Multihash that wraps a Hash that was produced by the
+// hashing function with the given MultihashCode.
+func NewMultihash(code MultihashCode, hash Hash) Multihash {
+	return Multihash{Code: code, Digest: hash}
+}
+
+// NewMultihashFromHash returns a Multihash that wraps a Hash that was produced
+// by the 256-bit SHA2 hashing function (see NewHash).
+func NewMultihashFromHash(hash Hash) Multihash {
+	return NewMultihash(MultihashSHA256, hash)
+}
+
+// NewMultihashFromData hashes a slice of bytes using the hashing function with
+// the given MultihashCode, and returns the resulting Multihash. An error is
+// returned if the MultihashCode is not known.
+func NewMultihashFromData(code MultihashCode, data []byte) (Multihash, error) {
+	hasher, err := code.Hasher()
+	if err != nil {
+		return Multihash{}, err
+	}
+	return NewMultihash(code, hasher.Sum(data)), nil
+}
+
+// NewMultihashFromBytes returns the Multihash that is encoded by the bytes. An
+// error is returned if the bytes are malformed, or if there are trailing bytes.
+func NewMultihashFromBytes(data []byte) (Multihash, error) {
+	mh := Multihash{}
+	rest, _, err := mh.Unmarshal(data, surge.MaxBytes)
+	if err != nil {
+		return Multihash{}, err
+	}
+	if len(rest) != 0 {
+		return Multihash{}, fmt.Errorf("expected len=%v, got len=%v", mh.SizeHint(), len(data))
+	}
+	return mh, nil
+}
+
+// NewMultihashFromString returns the Multihash that is represented by the
+// unpadded base64 URL string (see Multihash.String).
+func NewMultihashFromString(str string) (Multihash, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return Multihash{}, err
+	}
+	return NewMultihashFromBytes(decoded)
+}
+
+// Hash returns the Hash wrapped by the Multihash. An error is returned if the
+// Hash was not produced by the 256-bit SHA2 hashing function, because it would
+// be ambiguous with Hashes that were.
+func (mh Multihash) Hash() (Hash, error) {
+	if mh.Code != MultihashSHA256 {
+		return Hash{}, fmt.Errorf("expected code=%#x, got code=%#x", uint64(MultihashSHA256), uint64(mh.Code))
+	}
+	return mh.Digest, nil
+}
+
+// Equal compares one Multihash with another. If they are equal, then it
+// returns true, otherwise it returns false.
+func (mh Multihash) Equal(other *Multihash) bool {
+	return mh.Code == other.Code && mh.Digest.Equal(&other.Digest)
+}
+
+// Bytes returns the binary representation of the Multihash.
+func (mh Multihash) Bytes() []byte {
+	buf := make([]byte, mh.SizeHint())
+	n := binary.PutUvarint(buf, uint64(mh.Code))
+	n += binary.PutUvarint(buf[n:], SizeHintHash)
+	copy(buf[n:], mh.Digest[:])
+	return buf
+}
+
+// SizeHint returns the number of bytes required to represent a Multihash in
+// binary.
+func (mh Multihash) SizeHint() int {
+	return sizeHintUvarint(uint64(mh.Code)) + sizeHintUvarint(SizeHintHash) + SizeHintHash
+}
+
+// Marshal into binary. Unlike most types, the Multihash is not prefixed by its
+// length; it is self-delimiting.
+func (mh Multihash) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	sizeHint := mh.SizeHint()
+	if len(buf) < sizeHint || rem < sizeHint {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	copy(buf, mh.Bytes())
+	return buf[sizeHint:], rem - sizeHint, nil
+}
+
+// Unmarshal from binary. An error is returned if the length of the digest is
+// not 32 bytes.
+func (mh *Multihash) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	code, n, err := unmarshalUvarint(buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	length, m, err := unmarshalUvarint(buf[n:], rem-n)
+	if err != nil {
+		return buf, rem, err
+	}
+	if length != SizeHintHash {
+		return buf, rem, fmt.Errorf("expected len=%v, got len=%v", SizeHintHash, length)
+	}
+	buf, rem, err = mh.Digest.Unmarshal(buf[n+m:], rem-n-m)
+	if err != nil {
+		return buf, rem, err
+	}
+	mh.Code = MultihashCode(code)
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface for the Multihash type.
+// It is represented as an unpadded base64 string of its binary representation.
+func (mh Multihash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mh.String())
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the Multihash
+// type. It assumes that it has been represented as an unpadded base64 string
+// of its binary representation.
+func (mh *Multihash) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := NewMultihashFromString(str)
+	if err != nil {
+		return err
+	}
+	*mh = decoded
+	return nil
+}
+
+// String returns the unpadded base64 URL string representation of the binary
+// representation of the Multihash.
+func (mh Multihash) String() string {
+	return base64.RawURLEncoding.EncodeToString(mh.Bytes())
+}
+
+// unmarshalUvarint returns an unsigned varint, and the number of bytes that
+// were consumed. The varint must be minimally encoded, as required by the
+// multiformats unsigned varint spec.
+func unmarshalUvarint(buf []byte, rem int) (uint64, int, error) {
+	if len(buf) > rem {
+		buf = buf[:rem]
+	}
+	x, n := binary.Uvarint(buf)
+	if n == 0 {
+		return 0, 0, surge.ErrUnexpectedEndOfBuffer
+	}
+	if n < 0 || n != sizeHintUvarint(x) {
+		return 0, 0, fmt.Errorf("malformed varint")
+	}
+	return x, n, nil
+}
+
+// sizeHintUvarint returns the number of bytes required to represent an
+// unsigned varint.
+func sizeHintUvarint(x uint64) int {
+	buf := [binary.MaxVarintLen64]byte{}
+	return binary.PutUvarint(buf[:], x)
+}
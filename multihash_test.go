@@ -0,0 +1,169 @@
+package id_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multihashes", func() {
+	codes := []id.MultihashCode{
+		id.MultihashSHA256,
+		id.MultihashSHA3256,
+		id.MultihashKeccak256,
+		id.MultihashBlake2b256,
+	}
+
+	Context("when encoding a known multihash", func() {
+		It("should be compatible with the multiformats spec", func() {
+			mh, err := id.NewMultihashFromData(id.MultihashSHA256, []byte("multihash"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hex.EncodeToString(mh.Bytes())).To(Equal("12209cbc07c3f991725836a3aa2a581ca2029198aa420b9d99bc0e131d9f3e2cbe47"))
+		})
+
+		It("should encode multi-byte codes as varints", func() {
+			mh := id.NewMultihash(id.MultihashBlake2b256, id.Hash{})
+			Expect(mh.SizeHint()).To(Equal(3 + 1 + id.SizeHintHash))
+			Expect(mh.Bytes()[:4]).To(Equal([]byte{0xa0, 0xe4, 0x02, 0x20}))
+		})
+	})
+
+	Context("when hashing data", func() {
+		It("should use the hasher for the code", func() {
+			f := func(data []byte) bool {
+				for _, code := range codes {
+					hasher, err := code.Hasher()
+					Expect(err).ToNot(HaveOccurred())
+					mh, err := id.NewMultihashFromData(code, data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(mh.Code).To(Equal(code))
+					Expect(mh.Digest).To(Equal(hasher.Sum(data)))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error for unknown codes", func() {
+			_, err := id.NewMultihashFromData(id.MultihashCode(0x00), []byte{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when converting to and from hashes", func() {
+		It("should equal itself when the code is SHA256", func() {
+			f := func(data []byte) bool {
+				hash := id.NewHash(data)
+				mh := id.NewMultihashFromHash(hash)
+				converted, err := mh.Hash()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(converted).To(Equal(hash))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the code is not SHA256", func() {
+			f := func(data []byte) bool {
+				for _, code := range codes[1:] {
+					mh, err := id.NewMultihashFromData(code, data)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = mh.Hash()
+					Expect(err).To(HaveOccurred())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(code uint64, data []byte) bool {
+				mh := id.NewMultihash(id.MultihashCode(code), id.NewHash(data))
+				marshaled, err := surge.ToBinary(mh)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(marshaled).To(Equal(mh.Bytes()))
+				unmarshaled := id.Multihash{}
+				err = surge.FromBinary(&unmarshaled, marshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mh.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling with insufficient buffer", func() {
+		It("should return an error", func() {
+			f := func(code uint64, data []byte) bool {
+				mh := id.NewMultihash(id.MultihashCode(code), id.NewHash(data))
+				marshaled := mh.Bytes()
+				for i := 0; i < len(marshaled); i++ {
+					unmarshaled := id.Multihash{}
+					_, _, err := unmarshaled.Unmarshal(marshaled[:i], surge.MaxBytes)
+					Expect(err).To(HaveOccurred())
+					_, _, err = unmarshaled.Unmarshal(marshaled, i)
+					Expect(err).To(HaveOccurred())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling malformed bytes", func() {
+		It("should return an error for the wrong length", func() {
+			data := append([]byte{0x12, 0x14}, make([]byte, 20)...)
+			_, err := id.NewMultihashFromBytes(data)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error for non-minimal varints", func() {
+			data := append([]byte{0x92, 0x00, 0x20}, make([]byte, 32)...)
+			_, err := id.NewMultihashFromBytes(data)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error for trailing bytes", func() {
+			data := append(id.NewMultihashFromHash(id.Hash{}).Bytes(), 0x00)
+			_, err := id.NewMultihashFromBytes(data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using strings", func() {
+		It("should equal itself", func() {
+			f := func(code uint64, data []byte) bool {
+				mh := id.NewMultihash(id.MultihashCode(code), id.NewHash(data))
+				unmarshaled, err := id.NewMultihashFromString(mh.String())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mh.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using JSON", func() {
+		It("should equal itself", func() {
+			f := func(code uint64, data []byte) bool {
+				mh := id.NewMultihash(id.MultihashCode(code), id.NewHash(data))
+				marshaled, err := json.Marshal(mh)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := id.Multihash{}
+				err = json.Unmarshal(marshaled, &unmarshaled)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mh.Equal(&unmarshaled)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
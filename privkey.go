@@ -100,7 +100,8 @@ func NewPrivKey() *PrivKey {
 	return (*PrivKey)(privKey)
 }
 
-// Sign a Hash and return the resulting Signature, or error.
+// Sign a Hash and return the resulting Signature, or error. The Signature is
+// always canonical.
 func (privKey PrivKey) Sign(hash *Hash) (Signature, error) {
 	rsv, err := crypto.Sign(hash[:], (*ecdsa.PrivateKey)(&privKey))
 	if err != nil {
@@ -111,7 +112,7 @@ func (privKey PrivKey) Sign(hash *Hash) (Signature, error) {
 	}
 	signature := Signature{}
 	copy(signature[:], rsv)
-	return signature, nil
+	return signature.Normalize(), nil
 }
 
 // PubKey returns the ECDSA public key associated with this privey key.
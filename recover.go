@@ -0,0 +1,72 @@
+package id
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// RecoverSignatories returns the Signatory that signed each Hash to produce the
+// Signature at the same index, splitting the recovery across the given number
+// of goroutines. If the number of goroutines is not positive, then GOMAXPROCS
+// goroutines are used. The i-th Signatory and error are the same as those
+// returned by calling signatures[i].Signatory(&hashes[i]), so one invalid
+// Signature does not prevent the others from being recovered.
+//
+// If the context is done before every Signatory has been recovered, then the
+// context error is returned, and it is also used as the error of every
+// Signature that was not recovered. The Signatories that were recovered are
+// still returned. An error is also returned if the number of
+// Hashes is not the same as the number of Signatures.
+func RecoverSignatories(ctx context.Context, hashes []Hash, signatures []Signature, numWorkers int) ([]Signatory, []error, error) {
+	if len(hashes) != len(signatures) {
+		return nil, nil, fmt.Errorf("expected len=%v, got len=%v", len(hashes), len(signatures))
+	}
+	if numWorkers <= 0 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+	if numWorkers > len(hashes) {
+		numWorkers = len(hashes)
+	}
+
+	signatories := make([]Signatory, len(hashes))
+	errs := make([]error, len(hashes))
+	recovered := make([]bool, len(hashes))
+
+	// Workers take the next index from a shared counter, instead of splitting
+	// the input into fixed ranges, so that a slow worker does not hold up the
+	// rest of the batch.
+	next := int64(-1)
+	wg := new(sync.WaitGroup)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(hashes) {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				signatories[i], errs[i] = signatures[i].Signatory(&hashes[i])
+				recovered[i] = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	var err error
+	for i := range recovered {
+		if !recovered[i] {
+			err = ctx.Err()
+			errs[i] = err
+		}
+	}
+	return signatories, errs, err
+}
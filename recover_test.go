@@ -0,0 +1,153 @@
+package id_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch signatory recovery", func() {
+	Context("when recovering signatories", func() {
+		It("should return the signatories in the order of the inputs", func() {
+			hashes, sigs, expected := signedHashes(200, id.NewPrivKey(), id.NewPrivKey(), id.NewPrivKey())
+			for _, numWorkers := range []int{0, 1, 3, 16, 1000} {
+				signatories, errs, err := id.RecoverSignatories(context.Background(), hashes, sigs, numWorkers)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(signatories).To(Equal(expected))
+				Expect(errs).To(HaveLen(len(hashes)))
+				for _, err := range errs {
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}
+		})
+
+		It("should return an error for each invalid signature", func() {
+			hashes, sigs, expected := signedHashes(50, id.NewPrivKey(), id.NewPrivKey(), id.NewPrivKey())
+			for i := 0; i < len(sigs); i += 5 {
+				sigs[i] = id.Signature{}
+			}
+			signatories, errs, err := id.RecoverSignatories(context.Background(), hashes, sigs, 4)
+			Expect(err).ToNot(HaveOccurred())
+			for i := range hashes {
+				if i%5 == 0 {
+					Expect(errs[i]).To(HaveOccurred())
+					Expect(signatories[i]).To(Equal(id.Signatory{}))
+					continue
+				}
+				Expect(errs[i]).ToNot(HaveOccurred())
+				Expect(signatories[i]).To(Equal(expected[i]))
+			}
+		})
+
+		It("should return nothing when there is nothing to recover", func() {
+			signatories, errs, err := id.RecoverSignatories(context.Background(), nil, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signatories).To(BeEmpty())
+			Expect(errs).To(BeEmpty())
+		})
+	})
+
+	Context("when the number of hashes and signatures are different", func() {
+		It("should return an error", func() {
+			hashes, sigs, _ := signedHashes(10, id.NewPrivKey())
+			_, _, err := id.RecoverSignatories(context.Background(), hashes, sigs[:9], 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the context is done", func() {
+		It("should return the context error for every unrecovered signatory", func() {
+			hashes, sigs, _ := signedHashes(50, id.NewPrivKey())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			signatories, errs, err := id.RecoverSignatories(ctx, hashes, sigs, 4)
+			Expect(err).To(Equal(context.Canceled))
+			Expect(signatories).To(HaveLen(len(hashes)))
+			for _, err := range errs {
+				Expect(err).To(Equal(context.Canceled))
+			}
+		})
+
+		It("should return the signatories that were recovered", func() {
+			hashes, sigs, expected := signedHashes(50, id.NewPrivKey())
+			for _, numWorkers := range []int{1, 4} {
+				ctx := newCountdownContext(20)
+				signatories, errs, err := id.RecoverSignatories(ctx, hashes, sigs, numWorkers)
+				Expect(err).To(Equal(context.Canceled))
+				numRecovered := 0
+				for i := range hashes {
+					if errs[i] == nil {
+						Expect(signatories[i]).To(Equal(expected[i]))
+						numRecovered++
+						continue
+					}
+					Expect(errs[i]).To(Equal(context.Canceled))
+					Expect(signatories[i]).To(Equal(id.Signatory{}))
+				}
+				Expect(numRecovered).To(Equal(20))
+			}
+		})
+	})
+})
+
+// countdownContext is a context that is cancelled once Done has been called
+// more than a fixed number of times. RecoverSignatories checks the context
+// once before every recovery, so this cancels the context after a fixed number
+// of recoveries, regardless of how long each recovery takes.
+type countdownContext struct {
+	context.Context
+
+	n    int64
+	once sync.Once
+	done chan struct{}
+}
+
+func newCountdownContext(n int64) *countdownContext {
+	return &countdownContext{Context: context.Background(), n: n, done: make(chan struct{})}
+}
+
+func (ctx *countdownContext) Done() <-chan struct{} {
+	if atomic.AddInt64(&ctx.n, -1) < 0 {
+		ctx.once.Do(func() { close(ctx.done) })
+	}
+	return ctx.done
+}
+
+func (ctx *countdownContext) Err() error {
+	select {
+	case <-ctx.done:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+func BenchmarkRecoverSignatoriesSequential1000(b *testing.B) {
+	hashes, sigs, _ := signedHashes(1000, id.NewPrivKey())
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := range hashes {
+			if _, err := sigs[j].Signatory(&hashes[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkRecoverSignatories1000(b *testing.B) {
+	hashes, sigs, _ := signedHashes(1000, id.NewPrivKey())
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := id.RecoverSignatories(context.Background(), hashes, sigs, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,10 +2,10 @@ package id
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/renproject/surge"
@@ -30,6 +30,36 @@ func (signature Signature) Signatory(hash *Hash) (Signatory, error) {
 	return NewSignatory((*PubKey)(pubKey)), nil
 }
 
+// IsCanonical returns true if the Signature is well-formed and its S value is
+// in the lower half of the range, otherwise it returns false. Signatures
+// produced by PrivKey.Sign are always canonical.
+func (signature Signature) IsCanonical() bool {
+	return validateSignature(&signature, true) == nil
+}
+
+// Normalize returns the canonical encoding of the Signature. For every
+// signature (R, S, V) there is a second signature (R, N-S, 1-V) that verifies
+// against the same Hash and Signatory, so a third party can change the bytes
+// of a Signature without invalidating it. If S is greater than N/2, then the
+// second signature is returned. Otherwise, the Signature is returned
+// unchanged. Signatures that are malformed are also returned unchanged.
+func (signature Signature) Normalize() Signature {
+	if validateSignature(&signature, false) != nil {
+		return signature
+	}
+	s := new(big.Int).SetBytes(signature[32:64])
+	if s.Cmp(secp256k1HalfN) <= 0 {
+		return signature
+	}
+	s.Sub(secp256k1N, s)
+	normalized := signature
+	sData := s.Bytes()
+	copy(normalized[32:64], make([]byte, 32))
+	copy(normalized[64-len(sData):64], sData)
+	normalized[64] ^= 1
+	return normalized
+}
+
 // Equal compares one Signature with another. If they are equal, then it returns
 // true, otherwise it returns false.
 func (signature Signature) Equal(other *Signature) bool {
@@ -95,6 +125,12 @@ type Signatory [SizeHintSignatory]byte
 
 // NewSignatory returns the the Signatory of the given ECSDA.PublicKey
 func NewSignatory(pubKey *PubKey) Signatory {
+	return NewSignatoryWithHasher(pubKey, SHA256Hasher)
+}
+
+// NewSignatoryWithHasher is the same as NewSignatory, but it uses the given
+// Hasher to hash the public key instead of SHA2 256-bit.
+func NewSignatoryWithHasher(pubKey *PubKey, hasher Hasher) Signatory {
 	x := [32]byte{}
 	xData := pubKey.X.Bytes()
 	copy(x[32-len(xData):], xData)
@@ -104,7 +140,7 @@ func NewSignatory(pubKey *PubKey) Signatory {
 	copy(y[32-len(yData):], yData)
 
 	pubKeyData := append(x[:], y[:]...)
-	return Signatory(sha256.Sum256(pubKeyData))
+	return Signatory(hasher.Sum(pubKeyData))
 }
 
 // Equal compares one Signatory with another. If they are equal, then it returns
@@ -12,6 +12,38 @@ import (
 )
 
 var _ = Describe("Signatures", func() {
+	Context("when normalizing a signature", func() {
+		It("should return the canonical encoding", func() {
+			f := func(hash id.Hash) bool {
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sig.IsCanonical()).To(BeTrue())
+				Expect(sig.Normalize()).To(Equal(sig))
+
+				malleated := malleate(sig)
+				Expect(malleated.IsCanonical()).To(BeFalse())
+				normalized := malleated.Normalize()
+				Expect(normalized).To(Equal(sig))
+				Expect(normalized.IsCanonical()).To(BeTrue())
+				Expect(normalized.Normalize()).To(Equal(normalized))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not change malformed signatures", func() {
+			f := func(data [65]byte) bool {
+				sig := id.Signature(data)
+				sig[64] = 2
+				Expect(sig.IsCanonical()).To(BeFalse())
+				Expect(sig.Normalize()).To(Equal(sig))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
 	Context("when marshaling and then unmarshaling using binary", func() {
 		It("should equal itself", func() {
 			f := func(data [65]byte) bool {
@@ -0,0 +1,131 @@
+package id
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// DefaultSignatoryCacheCapacity is the default number of Signatories stored by
+// a SignatoryCache.
+const DefaultSignatoryCacheCapacity = 4096
+
+// SignatoryCacheStats are the statistics of a SignatoryCache.
+type SignatoryCacheStats struct {
+	// Hits is the number of Signatories that were returned from the cache.
+	Hits uint64
+	// Misses is the number of Signatories that had to be recovered.
+	Misses uint64
+	// Evictions is the number of Signatories that were removed from the cache
+	// to make room for another Signatory.
+	Evictions uint64
+}
+
+// A SignatoryCache recovers Signatories from Signatures, and stores the
+// Signatories of the most recently used Hash and Signature pairs, so that
+// recovering the same Signatory again is cheap. When the cache is full, the
+// least recently used Signatory is evicted. Errors are not cached. It is safe
+// for concurrent use.
+type SignatoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[signatoryCacheKey]*list.Element
+	order    *list.List
+	stats    SignatoryCacheStats
+}
+
+type signatoryCacheKey [SizeHintHash + SizeHintSignature]byte
+
+type signatoryCacheEntry struct {
+	key       signatoryCacheKey
+	signatory Signatory
+}
+
+// NewSignatoryCache returns an empty SignatoryCache that stores at most the
+// given number of Signatories. It panics if the capacity is less than one.
+func NewSignatoryCache(capacity int) *SignatoryCache {
+	if capacity < 1 {
+		panic(fmt.Errorf("expected capacity>0, got capacity=%v", capacity))
+	}
+	return &SignatoryCache{
+		capacity: capacity,
+		entries:  make(map[signatoryCacheKey]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Signatory returns the Signatory that signed the Hash to produce the
+// Signature. It is the same as calling Signature.Signatory, but the Signatory
+// is only recovered if it is not already in the cache.
+func (cache *SignatoryCache) Signatory(hash *Hash, signature *Signature) (Signatory, error) {
+	key := signatoryCacheKey{}
+	copy(key[:SizeHintHash], hash[:])
+	copy(key[SizeHintHash:], signature[:])
+
+	cache.mu.Lock()
+	if elem, ok := cache.entries[key]; ok {
+		cache.order.MoveToFront(elem)
+		cache.stats.Hits++
+		signatory := elem.Value.(*signatoryCacheEntry).signatory
+		cache.mu.Unlock()
+		return signatory, nil
+	}
+	cache.stats.Misses++
+	cache.mu.Unlock()
+
+	// Recovery is expensive, so it is done without holding the lock. This
+	// means that concurrent misses for the same key can recover the Signatory
+	// more than once.
+	signatory, err := signature.Signatory(hash)
+	if err != nil {
+		return Signatory{}, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if elem, ok := cache.entries[key]; ok {
+		cache.order.MoveToFront(elem)
+		return signatory, nil
+	}
+	if cache.order.Len() >= cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*signatoryCacheEntry).key)
+		cache.stats.Evictions++
+	}
+	cache.entries[key] = cache.order.PushFront(&signatoryCacheEntry{key: key, signatory: signatory})
+	return signatory, nil
+}
+
+// Len returns the number of Signatories in the SignatoryCache.
+func (cache *SignatoryCache) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.order.Len()
+}
+
+// Capacity returns the maximum number of Signatories in the SignatoryCache.
+func (cache *SignatoryCache) Capacity() int {
+	return cache.capacity
+}
+
+// Stats returns the statistics of the SignatoryCache.
+func (cache *SignatoryCache) Stats() SignatoryCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.stats
+}
+
+// Purge removes every Signatory from the SignatoryCache and resets its
+// statistics.
+func (cache *SignatoryCache) Purge() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[signatoryCacheKey]*list.Element, cache.capacity)
+	cache.order.Init()
+	cache.stats = SignatoryCacheStats{}
+}
@@ -0,0 +1,200 @@
+package id_test
+
+import (
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// signedHashes returns n distinct hashes, their signatures, and the signatories
+// that signed them. The i-th hash is signed by the i-th private key, cycling
+// through the private keys.
+func signedHashes(n int, privKeys ...*id.PrivKey) ([]id.Hash, []id.Signature, []id.Signatory) {
+	hashes := make([]id.Hash, n)
+	sigs := make([]id.Signature, n)
+	signatories := make([]id.Signatory, n)
+	for i := range hashes {
+		privKey := privKeys[i%len(privKeys)]
+		hashes[i] = id.NewHash([]byte{byte(i), byte(i >> 8), byte(i >> 16)})
+		sig, err := privKey.Sign(&hashes[i])
+		if err != nil {
+			panic(err)
+		}
+		sigs[i] = sig
+		signatories[i] = privKey.Signatory()
+	}
+	return hashes, sigs, signatories
+}
+
+var _ = Describe("Signatory caches", func() {
+	Context("when recovering signatories", func() {
+		It("should return the same signatory as the signature", func() {
+			cache := id.NewSignatoryCache(id.DefaultSignatoryCacheCapacity)
+			f := func(hash id.Hash) bool {
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				for i := 0; i < 2; i++ {
+					signatory, err := cache.Signatory(&hash, &sig)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(signatory).To(Equal(privKey.Signatory()))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+			Expect(cache.Stats().Hits).To(Equal(cache.Stats().Misses))
+		})
+
+		It("should count hits and misses", func() {
+			privKey := id.NewPrivKey()
+			hashes, sigs, _ := signedHashes(10, privKey)
+			cache := id.NewSignatoryCache(10)
+			for i := 0; i < 3; i++ {
+				for j := range hashes {
+					_, err := cache.Signatory(&hashes[j], &sigs[j])
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}
+			Expect(cache.Stats()).To(Equal(id.SignatoryCacheStats{Hits: 20, Misses: 10}))
+			Expect(cache.Len()).To(Equal(10))
+
+			cache.Purge()
+			Expect(cache.Stats()).To(Equal(id.SignatoryCacheStats{}))
+			Expect(cache.Len()).To(Equal(0))
+		})
+
+		It("should not cache errors", func() {
+			cache := id.NewSignatoryCache(10)
+			hash := id.Hash{}
+			sig := id.Signature{}
+			for i := 0; i < 2; i++ {
+				_, err := cache.Signatory(&hash, &sig)
+				Expect(err).To(HaveOccurred())
+			}
+			Expect(cache.Stats()).To(Equal(id.SignatoryCacheStats{Misses: 2}))
+			Expect(cache.Len()).To(Equal(0))
+		})
+
+		It("should distinguish between signatures of the same hash", func() {
+			cache := id.NewSignatoryCache(10)
+			hash := id.Hash{1}
+			for i := 0; i < 5; i++ {
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatory, err := cache.Signatory(&hash, &sig)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(signatory).To(Equal(privKey.Signatory()))
+			}
+			Expect(cache.Stats().Hits).To(Equal(uint64(0)))
+		})
+	})
+
+	Context("when the cache is full", func() {
+		It("should evict the least recently used signatory", func() {
+			privKey := id.NewPrivKey()
+			hashes, sigs, _ := signedHashes(4, privKey)
+			cache := id.NewSignatoryCache(3)
+			Expect(cache.Capacity()).To(Equal(3))
+			for i := 0; i < 3; i++ {
+				_, err := cache.Signatory(&hashes[i], &sigs[i])
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			// Use the first signatory, so that the second signatory is the
+			// least recently used, and then add the fourth signatory.
+			_, err := cache.Signatory(&hashes[0], &sigs[0])
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cache.Signatory(&hashes[3], &sigs[3])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache.Len()).To(Equal(3))
+			Expect(cache.Stats()).To(Equal(id.SignatoryCacheStats{Hits: 1, Misses: 4, Evictions: 1}))
+
+			for _, i := range []int{0, 2, 3} {
+				_, err := cache.Signatory(&hashes[i], &sigs[i])
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(cache.Stats().Hits).To(Equal(uint64(4)))
+			_, err = cache.Signatory(&hashes[1], &sigs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache.Stats().Misses).To(Equal(uint64(5)))
+		})
+	})
+
+	Context("when the capacity is not positive", func() {
+		It("should panic", func() {
+			Expect(func() { id.NewSignatoryCache(0) }).To(Panic())
+			Expect(func() { id.NewSignatoryCache(-1) }).To(Panic())
+		})
+	})
+
+	Context("when recovering signatories concurrently", func() {
+		It("should return the correct signatories", func() {
+			privKey := id.NewPrivKey()
+			hashes, sigs, _ := signedHashes(50, privKey)
+			cache := id.NewSignatoryCache(20)
+			wg := new(sync.WaitGroup)
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						k := (i*7 + j) % len(hashes)
+						signatory, err := cache.Signatory(&hashes[k], &sigs[k])
+						Expect(err).ToNot(HaveOccurred())
+						Expect(signatory).To(Equal(privKey.Signatory()))
+					}
+				}(i)
+			}
+			wg.Wait()
+			stats := cache.Stats()
+			Expect(stats.Hits + stats.Misses).To(Equal(uint64(800)))
+			Expect(cache.Len()).To(BeNumerically("<=", 20))
+		})
+	})
+})
+
+func BenchmarkSignatorySignatory(b *testing.B) {
+	hashes, sigs, _ := signedHashes(100, id.NewPrivKey())
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := sigs[i%100].Signatory(&hashes[i%100]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignatoryCacheSignatory(b *testing.B) {
+	hashes, sigs, _ := signedHashes(100, id.NewPrivKey())
+	cache := id.NewSignatoryCache(100)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := cache.Signatory(&hashes[i%100], &sigs[i%100]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignatoryCacheSignatoryParallel(b *testing.B) {
+	hashes, sigs, _ := signedHashes(100, id.NewPrivKey())
+	cache := id.NewSignatoryCache(100)
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := cache.Signatory(&hashes[i%100], &sigs[i%100]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
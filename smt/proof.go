@@ -0,0 +1,270 @@
+package smt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// A Proof proves that a key/value pair is in a Tree (an inclusion proof), or
+// that a key is not in a Tree (an exclusion proof).
+//
+// The proof is compressed: it stores the number of siblings on the path from
+// the root to the key, and a bitmask where the i-th bit is set if, and only
+// if, the sibling at depth i is non-empty. Only the non-empty siblings are
+// stored, ordered from the root to the key.
+//
+// Because the Tree is compacted, the path to a key that is not in the Tree can
+// end at a leaf for a different key. In this case, the exclusion proof also
+// stores the key, and the hash of the value, of that leaf.
+type Proof struct {
+	NumSiblings uint16
+	Bitmask     []byte
+	Siblings    []id.Hash
+
+	HasOtherLeaf       bool
+	OtherLeafKey       id.Hash
+	OtherLeafValueHash id.Hash
+}
+
+// Prove returns a Proof for the key. If the key is in the Tree, the Proof is
+// an inclusion proof for its value. Otherwise, the Proof is an exclusion
+// proof.
+func (tree *Tree) Prove(key id.Hash) (Proof, error) {
+	proof := Proof{Bitmask: make([]byte, 0, Depth/8)}
+	hash := tree.root
+	for depth := 0; hash != (id.Hash{}); depth++ {
+		n, err := tree.node(hash)
+		if err != nil {
+			return Proof{}, err
+		}
+		if n.isLeaf {
+			if n.key != key {
+				proof.HasOtherLeaf = true
+				proof.OtherLeafKey = n.key
+				proof.OtherLeafValueHash = id.NewHash(n.value)
+			}
+			break
+		}
+
+		sibling := n.left
+		hash = n.right
+		if bit(&key, depth) == 0 {
+			sibling, hash = n.right, n.left
+		}
+		if depth%8 == 0 {
+			proof.Bitmask = append(proof.Bitmask, 0)
+		}
+		if sibling != (id.Hash{}) {
+			proof.Bitmask[depth/8] |= 1 << uint(7-depth%8)
+			proof.Siblings = append(proof.Siblings, sibling)
+		}
+		proof.NumSiblings++
+	}
+	return proof, nil
+}
+
+// VerifyProof returns true if the Proof proves that the key has the given
+// value in the Tree with the given root hash, otherwise it returns false. An
+// empty value is used to verify that the key is not in the Tree.
+func VerifyProof(root, key *id.Hash, value []byte, proof *Proof) bool {
+	if !proof.isWellFormed() {
+		return false
+	}
+
+	hash := id.Hash{}
+	switch {
+	case len(value) > 0:
+		if proof.HasOtherLeaf {
+			return false
+		}
+		valueHash := id.NewHash(value)
+		hash = hashLeaf(key, &valueHash)
+	case proof.HasOtherLeaf:
+		// The other leaf must be on the path to the key, otherwise it could
+		// not be where the key would be.
+		if proof.OtherLeafKey == *key {
+			return false
+		}
+		for depth := 0; depth < int(proof.NumSiblings); depth++ {
+			if bit(&proof.OtherLeafKey, depth) != bit(key, depth) {
+				return false
+			}
+		}
+		hash = hashLeaf(&proof.OtherLeafKey, &proof.OtherLeafValueHash)
+	}
+
+	siblings := proof.Siblings
+	for depth := int(proof.NumSiblings) - 1; depth >= 0; depth-- {
+		sibling := id.Hash{}
+		if proof.Bitmask[depth/8]&(1<<uint(7-depth%8)) != 0 {
+			sibling = siblings[len(siblings)-1]
+			siblings = siblings[:len(siblings)-1]
+		}
+		if bit(key, depth) == 0 {
+			hash = hashNode(&hash, &sibling)
+		} else {
+			hash = hashNode(&sibling, &hash)
+		}
+	}
+	return hash.Equal(root)
+}
+
+// isWellFormed returns true if the number of siblings, the bitmask, and the
+// non-empty siblings are consistent with each other.
+func (proof *Proof) isWellFormed() bool {
+	if proof.NumSiblings > Depth || len(proof.Bitmask) != (int(proof.NumSiblings)+7)/8 {
+		return false
+	}
+	numNonEmpty := 0
+	for i := 0; i < 8*len(proof.Bitmask); i++ {
+		if proof.Bitmask[i/8]&(1<<uint(7-i%8)) == 0 {
+			continue
+		}
+		if i >= int(proof.NumSiblings) {
+			return false
+		}
+		numNonEmpty++
+	}
+	return numNonEmpty == len(proof.Siblings)
+}
+
+// Equal compares one Proof with another. If they are equal, then it returns
+// true, otherwise it returns false.
+func (proof Proof) Equal(other *Proof) bool {
+	if proof.NumSiblings != other.NumSiblings ||
+		len(proof.Bitmask) != len(other.Bitmask) ||
+		len(proof.Siblings) != len(other.Siblings) ||
+		proof.HasOtherLeaf != other.HasOtherLeaf ||
+		!proof.OtherLeafKey.Equal(&other.OtherLeafKey) ||
+		!proof.OtherLeafValueHash.Equal(&other.OtherLeafValueHash) {
+		return false
+	}
+	for i := range proof.Bitmask {
+		if proof.Bitmask[i] != other.Bitmask[i] {
+			return false
+		}
+	}
+	for i := range proof.Siblings {
+		if !proof.Siblings[i].Equal(&other.Siblings[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeHint returns the number of bytes required to represent a Proof in
+// binary.
+func (proof Proof) SizeHint() int {
+	sizeHint := surge.SizeHintU16 + len(proof.Bitmask) +
+		surge.SizeHintU16 + len(proof.Siblings)*id.SizeHintHash +
+		surge.SizeHintBool
+	if proof.HasOtherLeaf {
+		sizeHint += 2 * id.SizeHintHash
+	}
+	return sizeHint
+}
+
+// Marshal into binary. The length of the bitmask is implied by the number of
+// siblings, and the other leaf is only marshaled if it exists.
+func (proof Proof) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	if !proof.isWellFormed() {
+		return buf, rem, fmt.Errorf("malformed proof")
+	}
+	buf, rem, err := surge.MarshalU16(proof.NumSiblings, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if len(buf) < len(proof.Bitmask) || rem < len(proof.Bitmask) {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	copy(buf, proof.Bitmask)
+	buf, rem = buf[len(proof.Bitmask):], rem-len(proof.Bitmask)
+	if buf, rem, err = surge.Marshal(proof.Siblings, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.MarshalBool(proof.HasOtherLeaf, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if !proof.HasOtherLeaf {
+		return buf, rem, nil
+	}
+	if buf, rem, err = proof.OtherLeafKey.Marshal(buf, rem); err != nil {
+		return buf, rem, err
+	}
+	return proof.OtherLeafValueHash.Marshal(buf, rem)
+}
+
+// Unmarshal from binary.
+func (proof *Proof) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.UnmarshalU16(&proof.NumSiblings, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if proof.NumSiblings > Depth {
+		return buf, rem, fmt.Errorf("expected siblings<=%v, got siblings=%v", Depth, proof.NumSiblings)
+	}
+	bitmaskLen := (int(proof.NumSiblings) + 7) / 8
+	if len(buf) < bitmaskLen || rem < bitmaskLen {
+		return buf, rem, surge.ErrUnexpectedEndOfBuffer
+	}
+	proof.Bitmask = make([]byte, bitmaskLen)
+	copy(proof.Bitmask, buf)
+	buf, rem = buf[bitmaskLen:], rem-bitmaskLen
+
+	siblingsLen := uint16(0)
+	if _, _, err := surge.UnmarshalU16(&siblingsLen, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if siblingsLen > proof.NumSiblings {
+		return buf, rem, fmt.Errorf("expected len<=%v, got len=%v", proof.NumSiblings, siblingsLen)
+	}
+	if buf, rem, err = surge.Unmarshal(&proof.Siblings, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	if buf, rem, err = surge.UnmarshalBool(&proof.HasOtherLeaf, buf, rem); err != nil {
+		return buf, rem, err
+	}
+	proof.OtherLeafKey = id.Hash{}
+	proof.OtherLeafValueHash = id.Hash{}
+	if proof.HasOtherLeaf {
+		if buf, rem, err = proof.OtherLeafKey.Unmarshal(buf, rem); err != nil {
+			return buf, rem, err
+		}
+		if buf, rem, err = proof.OtherLeafValueHash.Unmarshal(buf, rem); err != nil {
+			return buf, rem, err
+		}
+	}
+	if !proof.isWellFormed() {
+		return buf, rem, fmt.Errorf("malformed proof")
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface for the Proof type. It
+// is represented as an unpadded base64 string of its binary representation.
+func (proof Proof) MarshalJSON() ([]byte, error) {
+	buf, err := surge.ToBinary(proof)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface for the Proof type.
+// It assumes that it has been represented as an unpadded base64 string of its
+// binary representation.
+func (proof *Proof) UnmarshalJSON(data []byte) error {
+	str := ""
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return surge.FromBinary(proof, decoded)
+}
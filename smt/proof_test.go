@@ -0,0 +1,166 @@
+package smt_test
+
+import (
+	"encoding/json"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/smt"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sparse merkle tree proofs", func() {
+	newTree := func(n int) (*smt.Tree, map[id.Hash][]byte) {
+		tree := smt.New(smt.NewMemoryNodeStore())
+		kvs := randomKVs(n)
+		for key, value := range kvs {
+			Expect(tree.Update(key, value)).To(Succeed())
+		}
+		return tree, kvs
+	}
+
+	Context("when proving a key that is in the tree", func() {
+		It("should verify the inclusion of its value", func() {
+			f := func(n uint8) bool {
+				tree, kvs := newTree(int(n) + 1)
+				root := tree.Root()
+				for key, value := range kvs {
+					proof, err := tree.Prove(key)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(proof.HasOtherLeaf).To(BeFalse())
+					Expect(len(proof.Siblings)).To(BeNumerically("<=", int(proof.NumSiblings)))
+					Expect(smt.VerifyProof(&root, &key, value, &proof)).To(BeTrue())
+					Expect(smt.VerifyProof(&root, &key, randomValue(), &proof)).To(BeFalse())
+					Expect(smt.VerifyProof(&root, &key, nil, &proof)).To(BeFalse())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when proving a key that is not in the tree", func() {
+		It("should verify its exclusion", func() {
+			f := func(n uint8) bool {
+				tree, kvs := newTree(int(n))
+				root := tree.Root()
+				for i := 0; i < 10; i++ {
+					key := randomKey()
+					proof, err := tree.Prove(key)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(smt.VerifyProof(&root, &key, nil, &proof)).To(BeTrue())
+					Expect(smt.VerifyProof(&root, &key, randomValue(), &proof)).To(BeFalse())
+				}
+				for key := range kvs {
+					proof, err := tree.Prove(randomKey())
+					Expect(err).ToNot(HaveOccurred())
+					Expect(smt.VerifyProof(&root, &key, nil, &proof)).To(BeFalse())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify the exclusion of a key using its own leaf", func() {
+			tree, kvs := newTree(100)
+			root := tree.Root()
+			for key := range kvs {
+				proof, err := tree.Prove(key)
+				Expect(err).ToNot(HaveOccurred())
+				proof.HasOtherLeaf = true
+				proof.OtherLeafKey = key
+				proof.OtherLeafValueHash = id.NewHash(kvs[key])
+				Expect(smt.VerifyProof(&root, &key, nil, &proof)).To(BeFalse())
+			}
+		})
+	})
+
+	Context("when the proof is malformed", func() {
+		It("should not verify", func() {
+			tree, kvs := newTree(100)
+			root := tree.Root()
+			for key, value := range kvs {
+				proof, err := tree.Prove(key)
+				Expect(err).ToNot(HaveOccurred())
+
+				malformed := proof
+				malformed.NumSiblings++
+				Expect(smt.VerifyProof(&root, &key, value, &malformed)).To(BeFalse())
+
+				malformed = proof
+				malformed.Siblings = append(malformed.Siblings, id.Hash{})
+				Expect(smt.VerifyProof(&root, &key, value, &malformed)).To(BeFalse())
+
+				malformed = proof
+				malformed.Bitmask = append(malformed.Bitmask, 0)
+				Expect(smt.VerifyProof(&root, &key, value, &malformed)).To(BeFalse())
+			}
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using binary", func() {
+		It("should equal itself", func() {
+			f := func(n uint8) bool {
+				tree, kvs := newTree(int(n))
+				keys := []id.Hash{randomKey()}
+				for key := range kvs {
+					keys = append(keys, key)
+				}
+				for _, key := range keys {
+					proof, err := tree.Prove(key)
+					Expect(err).ToNot(HaveOccurred())
+					marshaled, err := surge.ToBinary(proof)
+					Expect(err).ToNot(HaveOccurred())
+					unmarshaled := smt.Proof{}
+					Expect(surge.FromBinary(&unmarshaled, marshaled)).To(Succeed())
+					Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when unmarshaling random bytes using binary", func() {
+		It("should return an error", func() {
+			f := func(data []byte) bool {
+				if len(data) >= 5 {
+					return true
+				}
+				unmarshaled := smt.Proof{}
+				Expect(surge.FromBinary(&unmarshaled, data)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling using JSON", func() {
+		It("should equal itself", func() {
+			tree, kvs := newTree(100)
+			for key := range kvs {
+				proof, err := tree.Prove(key)
+				Expect(err).ToNot(HaveOccurred())
+				marshaled, err := json.Marshal(proof)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := smt.Proof{}
+				Expect(json.Unmarshal(marshaled, &unmarshaled)).To(Succeed())
+				Expect(proof.Equal(&unmarshaled)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("when unmarshaling random bytes using JSON", func() {
+		It("should return an error", func() {
+			f := func(data []byte) bool {
+				unmarshaled := smt.Proof{}
+				Expect(unmarshaled.UnmarshalJSON(data)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
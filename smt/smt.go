@@ -0,0 +1,365 @@
+// Package smt implements a sparse merkle tree that commits to key/value pairs
+// that are indexed by id.Hash.
+//
+// Conceptually, the tree has a leaf for every one of the 2^256 possible keys,
+// and the path to a leaf is given by the bits of its key (most significant bit
+// first, where 0 is left and 1 is right). Almost all of these leaves are empty,
+// so the tree is compacted: empty subtrees are represented by the empty Hash,
+// and a subtree that contains exactly one non-empty leaf is represented by
+// that leaf. Leaves are hashed as SHA2-256(0x00 || key || SHA2-256(value)) and
+// internal nodes are hashed as SHA2-256(0x01 || left || right), so leaves
+// cannot be confused with internal nodes.
+//
+// The root hash only depends on the set of key/value pairs in the tree, not
+// the order in which they were inserted.
+package smt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/renproject/id"
+)
+
+// ErrKeyNotFound is returned when getting, or deleting, a key that is not in
+// the Tree.
+var ErrKeyNotFound = errors.New("key not found")
+
+// Prefixes that are used to domain separate leaves from internal nodes.
+const (
+	leafPrefix = byte(0x00)
+	nodePrefix = byte(0x01)
+)
+
+// Depth is the maximum depth of the Tree. It is the number of bits in an
+// id.Hash key.
+const Depth = 8 * id.SizeHintHash
+
+// A Tree is a sparse merkle tree. Its nodes are persisted in a NodeStore, and
+// nodes that are no longer reachable from the root are deleted from the
+// NodeStore when the Tree is modified. A Tree is not safe for concurrent use.
+type Tree struct {
+	store NodeStore
+	root  id.Hash
+}
+
+// New returns an empty Tree that persists its nodes in the given NodeStore.
+func New(store NodeStore) *Tree {
+	return &Tree{store: store}
+}
+
+// NewWithRoot returns a Tree with the given root hash that loads its nodes from
+// the given NodeStore. This is used to reload a Tree that has been persisted.
+func NewWithRoot(store NodeStore, root id.Hash) *Tree {
+	return &Tree{store: store, root: root}
+}
+
+// Root returns the root hash of the Tree. The root hash of an empty Tree is
+// the empty Hash.
+func (tree *Tree) Root() id.Hash {
+	return tree.root
+}
+
+// Get the value of a key. ErrKeyNotFound is returned if the key is not in the
+// Tree.
+func (tree *Tree) Get(key id.Hash) ([]byte, error) {
+	hash := tree.root
+	for depth := 0; ; depth++ {
+		if hash == (id.Hash{}) {
+			return nil, ErrKeyNotFound
+		}
+		n, err := tree.node(hash)
+		if err != nil {
+			return nil, err
+		}
+		if n.isLeaf {
+			if n.key != key {
+				return nil, ErrKeyNotFound
+			}
+			return n.value, nil
+		}
+		if bit(&key, depth) == 0 {
+			hash = n.left
+		} else {
+			hash = n.right
+		}
+	}
+}
+
+// Update the value of a key. If the key is not in the Tree, it is inserted.
+// Updating a key to an empty value is the same as deleting it, except that no
+// error is returned if the key is not in the Tree.
+func (tree *Tree) Update(key id.Hash, value []byte) error {
+	if len(value) == 0 {
+		if err := tree.Delete(key); err != nil && err != ErrKeyNotFound {
+			return err
+		}
+		return nil
+	}
+	leaf := node{isLeaf: true, key: key, value: value}
+	root, err := tree.update(tree.root, 0, &leaf)
+	if err != nil {
+		return err
+	}
+	tree.root = root
+	return nil
+}
+
+// Delete a key. ErrKeyNotFound is returned if the key is not in the Tree.
+func (tree *Tree) Delete(key id.Hash) error {
+	root, err := tree.delete(tree.root, 0, &key)
+	if err != nil {
+		return err
+	}
+	tree.root = root
+	return nil
+}
+
+func (tree *Tree) update(hash id.Hash, depth int, leaf *node) (id.Hash, error) {
+	if hash == (id.Hash{}) {
+		return tree.put(leaf)
+	}
+	n, err := tree.node(hash)
+	if err != nil {
+		return id.Hash{}, err
+	}
+
+	if n.isLeaf {
+		if n.key == leaf.key {
+			updated, err := tree.put(leaf)
+			if err != nil {
+				return id.Hash{}, err
+			}
+			return updated, tree.drop(hash, updated)
+		}
+		// Split the existing leaf and the new leaf into their own subtrees, at
+		// the first bit where their keys differ.
+		leafHash, err := tree.put(leaf)
+		if err != nil {
+			return id.Hash{}, err
+		}
+		split := depth
+		for bit(&n.key, split) == bit(&leaf.key, split) {
+			split++
+		}
+		parent := node{left: hash, right: leafHash}
+		if bit(&leaf.key, split) == 0 {
+			parent.left, parent.right = leafHash, hash
+		}
+		parentHash, err := tree.put(&parent)
+		if err != nil {
+			return id.Hash{}, err
+		}
+		for split > depth {
+			split--
+			parent = node{left: parentHash}
+			if bit(&leaf.key, split) == 1 {
+				parent.left, parent.right = id.Hash{}, parentHash
+			}
+			if parentHash, err = tree.put(&parent); err != nil {
+				return id.Hash{}, err
+			}
+		}
+		return parentHash, nil
+	}
+
+	updated := n
+	if bit(&leaf.key, depth) == 0 {
+		if updated.left, err = tree.update(n.left, depth+1, leaf); err != nil {
+			return id.Hash{}, err
+		}
+	} else {
+		if updated.right, err = tree.update(n.right, depth+1, leaf); err != nil {
+			return id.Hash{}, err
+		}
+	}
+	updatedHash, err := tree.put(&updated)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	return updatedHash, tree.drop(hash, updatedHash)
+}
+
+func (tree *Tree) delete(hash id.Hash, depth int, key *id.Hash) (id.Hash, error) {
+	if hash == (id.Hash{}) {
+		return id.Hash{}, ErrKeyNotFound
+	}
+	n, err := tree.node(hash)
+	if err != nil {
+		return id.Hash{}, err
+	}
+
+	if n.isLeaf {
+		if n.key != *key {
+			return id.Hash{}, ErrKeyNotFound
+		}
+		return id.Hash{}, tree.drop(hash, id.Hash{})
+	}
+
+	child, sibling := n.left, n.right
+	if bit(key, depth) == 1 {
+		child, sibling = n.right, n.left
+	}
+	if child, err = tree.delete(child, depth+1, key); err != nil {
+		return id.Hash{}, err
+	}
+
+	// A subtree with exactly one leaf is compacted into the leaf. This means
+	// that the leaf is hoisted up the tree, until it is paired with another
+	// non-empty subtree.
+	var hoisted id.Hash
+	var ok bool
+	if child == (id.Hash{}) {
+		if hoisted, ok, err = tree.isLeaf(sibling); err != nil {
+			return id.Hash{}, err
+		}
+	} else if sibling == (id.Hash{}) {
+		if hoisted, ok, err = tree.isLeaf(child); err != nil {
+			return id.Hash{}, err
+		}
+	}
+	if ok {
+		return hoisted, tree.drop(hash, hoisted)
+	}
+
+	updated := node{left: child, right: sibling}
+	if bit(key, depth) == 1 {
+		updated.left, updated.right = sibling, child
+	}
+	updatedHash, err := tree.put(&updated)
+	if err != nil {
+		return id.Hash{}, err
+	}
+	return updatedHash, tree.drop(hash, updatedHash)
+}
+
+// isLeaf returns the hash, and true, if the hash is a leaf. Otherwise, it
+// returns false.
+func (tree *Tree) isLeaf(hash id.Hash) (id.Hash, bool, error) {
+	if hash == (id.Hash{}) {
+		return id.Hash{}, false, nil
+	}
+	n, err := tree.node(hash)
+	if err != nil {
+		return id.Hash{}, false, err
+	}
+	return hash, n.isLeaf, nil
+}
+
+// node loads and decodes the node with the given hash from the NodeStore.
+func (tree *Tree) node(hash id.Hash) (node, error) {
+	data, err := tree.store.Get(hash)
+	if err != nil {
+		return node{}, fmt.Errorf("loading node=%v: %v", hash, err)
+	}
+	n := node{}
+	if err := n.decode(data); err != nil {
+		return node{}, fmt.Errorf("decoding node=%v: %v", hash, err)
+	}
+	return n, nil
+}
+
+// put encodes and stores the node in the NodeStore, and returns its hash.
+func (tree *Tree) put(n *node) (id.Hash, error) {
+	hash := n.hash()
+	if err := tree.store.Put(hash, n.encode()); err != nil {
+		return id.Hash{}, fmt.Errorf("storing node=%v: %v", hash, err)
+	}
+	return hash, nil
+}
+
+// drop deletes the node with the given hash from the NodeStore, unless it has
+// been replaced by itself.
+func (tree *Tree) drop(hash, replacement id.Hash) error {
+	if hash == replacement {
+		return nil
+	}
+	if err := tree.store.Delete(hash); err != nil {
+		return fmt.Errorf("deleting node=%v: %v", hash, err)
+	}
+	return nil
+}
+
+// node is a decoded leaf, or internal node, of the Tree.
+type node struct {
+	isLeaf bool
+
+	// Leaves.
+	key   id.Hash
+	value []byte
+
+	// Internal nodes.
+	left  id.Hash
+	right id.Hash
+}
+
+func (n *node) hash() id.Hash {
+	if n.isLeaf {
+		valueHash := id.NewHash(n.value)
+		return hashLeaf(&n.key, &valueHash)
+	}
+	return hashNode(&n.left, &n.right)
+}
+
+func (n *node) encode() []byte {
+	if n.isLeaf {
+		data := make([]byte, 1+id.SizeHintHash+len(n.value))
+		data[0] = leafPrefix
+		copy(data[1:], n.key[:])
+		copy(data[1+id.SizeHintHash:], n.value)
+		return data
+	}
+	data := make([]byte, 1+2*id.SizeHintHash)
+	data[0] = nodePrefix
+	copy(data[1:], n.left[:])
+	copy(data[1+id.SizeHintHash:], n.right[:])
+	return data
+}
+
+func (n *node) decode(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("expected len>0, got len=0")
+	}
+	switch data[0] {
+	case leafPrefix:
+		if len(data) < 1+id.SizeHintHash {
+			return fmt.Errorf("expected len>=%v, got len=%v", 1+id.SizeHintHash, len(data))
+		}
+		n.isLeaf = true
+		copy(n.key[:], data[1:])
+		n.value = data[1+id.SizeHintHash:]
+	case nodePrefix:
+		if len(data) != 1+2*id.SizeHintHash {
+			return fmt.Errorf("expected len=%v, got len=%v", 1+2*id.SizeHintHash, len(data))
+		}
+		n.isLeaf = false
+		copy(n.left[:], data[1:])
+		copy(n.right[:], data[1+id.SizeHintHash:])
+	default:
+		return fmt.Errorf("unexpected prefix=%v", data[0])
+	}
+	return nil
+}
+
+// hashLeaf returns SHA2-256(0x00 || key || valueHash).
+func hashLeaf(key, valueHash *id.Hash) id.Hash {
+	buf := [1 + 2*id.SizeHintHash]byte{leafPrefix}
+	copy(buf[1:], key[:])
+	copy(buf[1+id.SizeHintHash:], valueHash[:])
+	return id.Hash(sha256.Sum256(buf[:]))
+}
+
+// hashNode returns SHA2-256(0x01 || left || right).
+func hashNode(left, right *id.Hash) id.Hash {
+	buf := [1 + 2*id.SizeHintHash]byte{nodePrefix}
+	copy(buf[1:], left[:])
+	copy(buf[1+id.SizeHintHash:], right[:])
+	return id.Hash(sha256.Sum256(buf[:]))
+}
+
+// bit returns the i-th bit of the key, where the 0-th bit is the most
+// significant bit of the first byte.
+func bit(key *id.Hash, i int) byte {
+	return (key[i/8] >> uint(7-i%8)) & 1
+}
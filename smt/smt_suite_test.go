@@ -0,0 +1,13 @@
+package smt_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSmt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sparse Merkle Tree Suite")
+}
@@ -0,0 +1,226 @@
+package smt_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	mrand "math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/smt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func randomKey() id.Hash {
+	key := id.Hash{}
+	rand.Read(key[:])
+	return key
+}
+
+// randomValue returns a random value that is long enough to never collide with
+// another random value.
+func randomValue() []byte {
+	value := make([]byte, mrand.Intn(64)+32)
+	rand.Read(value)
+	return value
+}
+
+func randomKVs(n int) map[id.Hash][]byte {
+	kvs := make(map[id.Hash][]byte, n)
+	for i := 0; i < n; i++ {
+		kvs[randomKey()] = randomValue()
+	}
+	return kvs
+}
+
+var _ = Describe("Sparse merkle trees", func() {
+	Context("when the tree is empty", func() {
+		It("should have the empty root", func() {
+			tree := smt.New(smt.NewMemoryNodeStore())
+			Expect(tree.Root()).To(Equal(id.Hash{}))
+			_, err := tree.Get(randomKey())
+			Expect(err).To(Equal(smt.ErrKeyNotFound))
+			Expect(tree.Delete(randomKey())).To(Equal(smt.ErrKeyNotFound))
+		})
+	})
+
+	Context("when updating keys", func() {
+		It("should get the latest values", func() {
+			f := func(n uint8) bool {
+				tree := smt.New(smt.NewMemoryNodeStore())
+				kvs := randomKVs(int(n))
+				for key, value := range kvs {
+					Expect(tree.Update(key, value)).To(Succeed())
+				}
+				for key := range kvs {
+					kvs[key] = randomValue()
+					Expect(tree.Update(key, kvs[key])).To(Succeed())
+				}
+				for key, value := range kvs {
+					got, err := tree.Get(key)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(bytes.Equal(got, value)).To(BeTrue())
+				}
+				_, err := tree.Get(randomKey())
+				Expect(err).To(Equal(smt.ErrKeyNotFound))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should have a root that does not depend on the order of updates", func() {
+			f := func(n uint8) bool {
+				kvs := randomKVs(int(n))
+				keys := make([]id.Hash, 0, len(kvs))
+				for key := range kvs {
+					keys = append(keys, key)
+				}
+
+				tree := smt.New(smt.NewMemoryNodeStore())
+				for _, key := range keys {
+					Expect(tree.Update(key, kvs[key])).To(Succeed())
+				}
+				mrand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+				shuffled := smt.New(smt.NewMemoryNodeStore())
+				for _, key := range keys {
+					// Insert a different value first, so that the tree has to
+					// replace it.
+					Expect(shuffled.Update(key, randomValue())).To(Succeed())
+				}
+				for _, key := range keys {
+					Expect(shuffled.Update(key, kvs[key])).To(Succeed())
+				}
+				Expect(shuffled.Root()).To(Equal(tree.Root()))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should be a no-op to update a key to its current value", func() {
+			store := smt.NewMemoryNodeStore()
+			tree := smt.New(store)
+			kvs := randomKVs(100)
+			for key, value := range kvs {
+				Expect(tree.Update(key, value)).To(Succeed())
+			}
+			root, numNodes := tree.Root(), store.Len()
+			for key, value := range kvs {
+				Expect(tree.Update(key, value)).To(Succeed())
+			}
+			Expect(tree.Root()).To(Equal(root))
+			Expect(store.Len()).To(Equal(numNodes))
+		})
+
+		It("should handle keys with long common prefixes", func() {
+			tree := smt.New(smt.NewMemoryNodeStore())
+			key1, key2 := id.Hash{}, id.Hash{}
+			key2[31] = 1
+			Expect(tree.Update(key1, []byte{1})).To(Succeed())
+			Expect(tree.Update(key2, []byte{2})).To(Succeed())
+			got, err := tree.Get(key2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal([]byte{2}))
+			Expect(tree.Delete(key2)).To(Succeed())
+
+			single := smt.New(smt.NewMemoryNodeStore())
+			Expect(single.Update(key1, []byte{1})).To(Succeed())
+			Expect(tree.Root()).To(Equal(single.Root()))
+		})
+	})
+
+	Context("when deleting keys", func() {
+		It("should have the same root as if the keys were never inserted", func() {
+			f := func(n, m uint8) bool {
+				kvs := randomKVs(int(n))
+				extra := randomKVs(int(m))
+
+				store := smt.NewMemoryNodeStore()
+				tree := smt.New(store)
+				expected := smt.New(smt.NewMemoryNodeStore())
+				for key, value := range kvs {
+					Expect(tree.Update(key, value)).To(Succeed())
+					Expect(expected.Update(key, value)).To(Succeed())
+				}
+				numNodes := store.Len()
+				for key, value := range extra {
+					Expect(tree.Update(key, value)).To(Succeed())
+				}
+				for key := range extra {
+					Expect(tree.Delete(key)).To(Succeed())
+					_, err := tree.Get(key)
+					Expect(err).To(Equal(smt.ErrKeyNotFound))
+				}
+				Expect(tree.Root()).To(Equal(expected.Root()))
+				Expect(store.Len()).To(Equal(numNodes))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should delete all nodes from the store when all keys are deleted", func() {
+			store := smt.NewMemoryNodeStore()
+			tree := smt.New(store)
+			kvs := randomKVs(100)
+			for key, value := range kvs {
+				Expect(tree.Update(key, value)).To(Succeed())
+			}
+			for key := range kvs {
+				Expect(tree.Update(key, nil)).To(Succeed())
+			}
+			Expect(tree.Root()).To(Equal(id.Hash{}))
+			Expect(store.Len()).To(Equal(0))
+		})
+
+		It("should return an error when the key does not exist", func() {
+			tree := smt.New(smt.NewMemoryNodeStore())
+			for key, value := range randomKVs(100) {
+				Expect(tree.Update(key, value)).To(Succeed())
+			}
+			root := tree.Root()
+			Expect(tree.Delete(randomKey())).To(Equal(smt.ErrKeyNotFound))
+			Expect(tree.Root()).To(Equal(root))
+		})
+	})
+
+	Context("when reloading a tree from its root", func() {
+		It("should get the same values", func() {
+			store := smt.NewMemoryNodeStore()
+			tree := smt.New(store)
+			kvs := randomKVs(100)
+			for key, value := range kvs {
+				Expect(tree.Update(key, value)).To(Succeed())
+			}
+			reloaded := smt.NewWithRoot(store, tree.Root())
+			for key, value := range kvs {
+				got, err := reloaded.Get(key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(got, value)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("when nodes are missing from the store", func() {
+		It("should return an error", func() {
+			tree := smt.NewWithRoot(smt.NewMemoryNodeStore(), randomKey())
+			_, err := tree.Get(randomKey())
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(Equal(smt.ErrKeyNotFound))
+			Expect(tree.Update(randomKey(), []byte{1})).ToNot(Succeed())
+			_, err = tree.Prove(randomKey())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func BenchmarkUpdate(b *testing.B) {
+	tree := smt.New(smt.NewMemoryNodeStore())
+	kvs := randomKVs(b.N)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for key, value := range kvs {
+		tree.Update(key, value)
+	}
+}
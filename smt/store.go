@@ -0,0 +1,85 @@
+package smt
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/renproject/id"
+)
+
+// ErrNodeNotFound is returned by a NodeStore when there is no node with the
+// requested Hash.
+var ErrNodeNotFound = errors.New("node not found")
+
+// A NodeStore persists the nodes of a Tree. Nodes are addressed by their Hash,
+// and the stored bytes are opaque to the NodeStore. Implementations can be
+// backed by a database, but must be safe for concurrent use.
+type NodeStore interface {
+	// Get the node with the given Hash. ErrNodeNotFound must be returned if
+	// there is no such node.
+	Get(hash id.Hash) ([]byte, error)
+	// Put a node with the given Hash. If the node already exists, it is
+	// overwritten.
+	Put(hash id.Hash, data []byte) error
+	// Delete the node with the given Hash. Deleting a node that does not
+	// exist is not an error.
+	Delete(hash id.Hash) error
+}
+
+// MemoryNodeStore is a NodeStore that keeps all nodes in memory. It is safe
+// for concurrent use.
+type MemoryNodeStore struct {
+	mu    *sync.RWMutex
+	nodes map[id.Hash][]byte
+}
+
+// NewMemoryNodeStore returns an empty MemoryNodeStore.
+func NewMemoryNodeStore() *MemoryNodeStore {
+	return &MemoryNodeStore{
+		mu:    new(sync.RWMutex),
+		nodes: map[id.Hash][]byte{},
+	}
+}
+
+// Get the node with the given Hash. ErrNodeNotFound is returned if there is no
+// such node.
+func (store *MemoryNodeStore) Get(hash id.Hash) ([]byte, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	data, ok := store.nodes[hash]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	return copied, nil
+}
+
+// Put a node with the given Hash.
+func (store *MemoryNodeStore) Put(hash id.Hash, data []byte) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	store.nodes[hash] = copied
+	return nil
+}
+
+// Delete the node with the given Hash.
+func (store *MemoryNodeStore) Delete(hash id.Hash) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.nodes, hash)
+	return nil
+}
+
+// Len returns the number of nodes in the MemoryNodeStore.
+func (store *MemoryNodeStore) Len() int {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return len(store.nodes)
+}
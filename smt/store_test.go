@@ -0,0 +1,64 @@
+package smt_test
+
+import (
+	"bytes"
+	"testing/quick"
+
+	"github.com/renproject/id"
+	"github.com/renproject/id/smt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory node stores", func() {
+	Context("when putting and then getting a node", func() {
+		It("should return the node", func() {
+			f := func(data []byte) bool {
+				store := smt.NewMemoryNodeStore()
+				hash := id.NewHash(data)
+				Expect(store.Put(hash, data)).To(Succeed())
+				got, err := store.Get(hash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(got, data)).To(BeTrue())
+				Expect(store.Len()).To(Equal(1))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not be affected by modifying the returned node", func() {
+			store := smt.NewMemoryNodeStore()
+			data := []byte{1, 2, 3}
+			hash := id.NewHash(data)
+			Expect(store.Put(hash, data)).To(Succeed())
+			data[0] = 0
+			got, err := store.Get(hash)
+			Expect(err).ToNot(HaveOccurred())
+			got[1] = 0
+			got, err = store.Get(hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal([]byte{1, 2, 3}))
+		})
+	})
+
+	Context("when getting a node that does not exist", func() {
+		It("should return an error", func() {
+			store := smt.NewMemoryNodeStore()
+			_, err := store.Get(id.Hash{})
+			Expect(err).To(Equal(smt.ErrNodeNotFound))
+		})
+	})
+
+	Context("when deleting a node", func() {
+		It("should not be found", func() {
+			store := smt.NewMemoryNodeStore()
+			Expect(store.Put(id.Hash{}, []byte{1})).To(Succeed())
+			Expect(store.Delete(id.Hash{})).To(Succeed())
+			Expect(store.Delete(id.Hash{})).To(Succeed())
+			_, err := store.Get(id.Hash{})
+			Expect(err).To(Equal(smt.ErrNodeNotFound))
+			Expect(store.Len()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package id
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrMalformedSignature is returned when a Signature is not a valid encoding of
+// a secp256k1 ECDSA signature, so no Signatory can be recovered from it.
+type ErrMalformedSignature struct {
+	Signature Signature
+	Reason    string
+}
+
+// Error implements the error interface.
+func (err ErrMalformedSignature) Error() string {
+	return fmt.Sprintf("malformed signature=%v: %v", err.Signature, err.Reason)
+}
+
+// ErrRecoveringSignatory is returned when a Signature is well-formed, but a
+// Signatory cannot be recovered from it and the Hash that it signs.
+type ErrRecoveringSignatory struct {
+	Signature Signature
+	Err       error
+}
+
+// Error implements the error interface.
+func (err ErrRecoveringSignatory) Error() string {
+	return fmt.Sprintf("recovering signatory from signature=%v: %v", err.Signature, err.Err)
+}
+
+// Unwrap returns the error returned by recovery.
+func (err ErrRecoveringSignatory) Unwrap() error {
+	return err.Err
+}
+
+// ErrSignatoryMismatch is returned when the Signatory recovered from a
+// Signature is not the expected Signatory.
+type ErrSignatoryMismatch struct {
+	Expected Signatory
+	Got      Signatory
+}
+
+// Error implements the error interface.
+func (err ErrSignatoryMismatch) Error() string {
+	return fmt.Sprintf("expected signatory=%v, got signatory=%v", err.Expected, err.Got)
+}
+
+// Verify that the Signature is a signature of the Hash by the Signatory. It
+// returns ErrMalformedSignature if the Signature is not well-formed,
+// ErrRecoveringSignatory if no Signatory can be recovered from the Signature,
+// and ErrSignatoryMismatch if the recovered Signatory is not the given
+// Signatory.
+//
+// Every Signature has a second encoding that verifies against the same Hash
+// and Signatory (see Signature.Normalize). Verify accepts both, so use
+// VerifyStrict when Signatures are identified by their bytes.
+func Verify(hash *Hash, signature *Signature, signatory *Signatory) error {
+	if err := validateSignature(signature, false); err != nil {
+		return err
+	}
+	return verify(hash, signature, signatory)
+}
+
+func verify(hash *Hash, signature *Signature, signatory *Signatory) error {
+	pubKey, err := crypto.SigToPub(hash[:], signature[:])
+	if err != nil {
+		return ErrRecoveringSignatory{Signature: *signature, Err: err}
+	}
+	got := NewSignatory((*PubKey)(pubKey))
+	if !got.Equal(signatory) {
+		return ErrSignatoryMismatch{Expected: *signatory, Got: got}
+	}
+	return nil
+}
+
+// VerifyStrict is the same as Verify, but it also returns
+// ErrMalformedSignature if the Signature is not canonical (its S value is in
+// the upper half of the range). This rejects the second encoding of every
+// Signature, so that the bytes of a verified Signature can be used to identify
+// it.
+func VerifyStrict(hash *Hash, signature *Signature, signatory *Signatory) error {
+	if err := validateSignature(signature, true); err != nil {
+		return err
+	}
+	return verify(hash, signature, signatory)
+}
+
+// Verify returns true if the Signature is a signature of the Hash by the
+// private key associated with this PubKey, otherwise it returns false.
+func (pubKey *PubKey) Verify(hash *Hash, signature *Signature) bool {
+	signatory := NewSignatory(pubKey)
+	return Verify(hash, signature, &signatory) == nil
+}
+
+// VerifyStrict is the same as Verify, but it also returns false if the
+// Signature is not canonical.
+func (pubKey *PubKey) VerifyStrict(hash *Hash, signature *Signature) bool {
+	signatory := NewSignatory(pubKey)
+	return VerifyStrict(hash, signature, &signatory) == nil
+}
+
+// validateSignature returns ErrMalformedSignature if the R or S values of the
+// Signature are not in the range [1, N) of the secp256k1 curve, or if the V
+// value is not 0 or 1. If strict is true, then it also returns
+// ErrMalformedSignature if the S value is greater than N/2.
+func validateSignature(signature *Signature, strict bool) error {
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	v := signature[64]
+	switch {
+	case r.Sign() == 0 || r.Cmp(secp256k1N) >= 0:
+		return ErrMalformedSignature{Signature: *signature, Reason: "r out of range"}
+	case s.Sign() == 0 || s.Cmp(secp256k1N) >= 0:
+		return ErrMalformedSignature{Signature: *signature, Reason: "s out of range"}
+	case v != 0 && v != 1:
+		return ErrMalformedSignature{Signature: *signature, Reason: fmt.Sprintf("v=%v", v)}
+	case strict && s.Cmp(secp256k1HalfN) > 0:
+		return ErrMalformedSignature{Signature: *signature, Reason: "s not canonical"}
+	}
+	return nil
+}
+
+var (
+	// secp256k1N is the order of the secp256k1 curve.
+	secp256k1N = crypto.S256().Params().N
+	// secp256k1HalfN is the largest canonical S value.
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
@@ -0,0 +1,202 @@
+package id_test
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing/quick"
+
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// secp256k1N is the order of the secp256k1 curve, in big-endian bytes.
+var secp256k1N = [32]byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+	0xba, 0xae, 0xdc, 0xe6, 0xaf, 0x48, 0xa0, 0x3b,
+	0xbf, 0xd2, 0x5e, 0x8c, 0xd0, 0x36, 0x41, 0x41,
+}
+
+// malleate returns the second encoding (R, N-S, 1-V) of the signature.
+func malleate(sig id.Signature) id.Signature {
+	s := new(big.Int).SetBytes(sig[32:64])
+	s.Sub(new(big.Int).SetBytes(secp256k1N[:]), s)
+	malleated := sig
+	copy(malleated[32:64], make([]byte, 32))
+	sData := s.Bytes()
+	copy(malleated[64-len(sData):64], sData)
+	malleated[64] ^= 1
+	return malleated
+}
+
+var _ = Describe("Signature verification", func() {
+	Context("when verifying a signature by the signatory", func() {
+		It("should succeed", func() {
+			f := func(hash id.Hash) bool {
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatory := privKey.Signatory()
+				Expect(id.Verify(&hash, &sig, &signatory)).To(Succeed())
+				Expect(privKey.PubKey().Verify(&hash, &sig)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying a signature by another signatory", func() {
+		It("should return a mismatch error", func() {
+			f := func(hash id.Hash) bool {
+				privKey := id.NewPrivKey()
+				other := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatory := other.Signatory()
+				err = id.Verify(&hash, &sig, &signatory)
+				errSignatoryMismatch := id.ErrSignatoryMismatch{}
+				Expect(errors.As(err, &errSignatoryMismatch)).To(BeTrue())
+				Expect(errSignatoryMismatch.Expected).To(Equal(other.Signatory()))
+				Expect(errSignatoryMismatch.Got).To(Equal(privKey.Signatory()))
+				Expect(other.PubKey().Verify(&hash, &sig)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying a signature of another hash", func() {
+		It("should fail", func() {
+			f := func(hash, other id.Hash) bool {
+				if hash.Equal(&other) {
+					return true
+				}
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatory := privKey.Signatory()
+				Expect(id.Verify(&other, &sig, &signatory)).ToNot(Succeed())
+				Expect(privKey.PubKey().Verify(&other, &sig)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying a malformed signature", func() {
+		It("should return a malformed signature error", func() {
+			hash := id.Hash{1}
+			privKey := id.NewPrivKey()
+			sig, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatory := privKey.Signatory()
+
+			malformed := []id.Signature{}
+			zeroR := sig
+			copy(zeroR[:32], make([]byte, 32))
+			malformed = append(malformed, zeroR)
+			overflowR := sig
+			copy(overflowR[:32], secp256k1N[:])
+			malformed = append(malformed, overflowR)
+			zeroS := sig
+			copy(zeroS[32:64], make([]byte, 32))
+			malformed = append(malformed, zeroS)
+			overflowS := sig
+			copy(overflowS[32:64], secp256k1N[:])
+			malformed = append(malformed, overflowS)
+			for _, v := range []byte{2, 27, 28, 255} {
+				invalidV := sig
+				invalidV[64] = v
+				malformed = append(malformed, invalidV)
+			}
+
+			for _, sig := range malformed {
+				err := id.Verify(&hash, &sig, &signatory)
+				errMalformedSignature := id.ErrMalformedSignature{}
+				Expect(errors.As(err, &errMalformedSignature)).To(BeTrue())
+				Expect(errMalformedSignature.Signature).To(Equal(sig))
+				Expect(privKey.PubKey().Verify(&hash, &sig)).To(BeFalse())
+			}
+		})
+	})
+
+	Context("when verifying a malleated signature", func() {
+		It("should only succeed when verification is not strict", func() {
+			f := func(hash id.Hash) bool {
+				privKey := id.NewPrivKey()
+				sig, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				malleated := malleate(sig)
+				Expect(malleated).ToNot(Equal(sig))
+				signatory := privKey.Signatory()
+
+				// Both encodings recover the same signatory.
+				got, err := malleated.Signatory(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(got).To(Equal(signatory))
+				Expect(id.Verify(&hash, &sig, &signatory)).To(Succeed())
+				Expect(id.Verify(&hash, &malleated, &signatory)).To(Succeed())
+				Expect(privKey.PubKey().Verify(&hash, &malleated)).To(BeTrue())
+
+				// Only the canonical encoding is accepted by strict
+				// verification.
+				Expect(id.VerifyStrict(&hash, &sig, &signatory)).To(Succeed())
+				Expect(privKey.PubKey().VerifyStrict(&hash, &sig)).To(BeTrue())
+				err = id.VerifyStrict(&hash, &malleated, &signatory)
+				errMalformedSignature := id.ErrMalformedSignature{}
+				Expect(errors.As(err, &errMalformedSignature)).To(BeTrue())
+				Expect(errMalformedSignature.Signature).To(Equal(malleated))
+				Expect(privKey.PubKey().VerifyStrict(&hash, &malleated)).To(BeFalse())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should reject invalid V values when verification is strict", func() {
+			hash := id.Hash{1}
+			privKey := id.NewPrivKey()
+			sig, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatory := privKey.Signatory()
+			for _, v := range []byte{2, 27, 28, 255} {
+				invalidV := sig
+				invalidV[64] = v
+				err := id.VerifyStrict(&hash, &invalidV, &signatory)
+				Expect(errors.As(err, &id.ErrMalformedSignature{})).To(BeTrue())
+			}
+		})
+	})
+
+	Context("when verifying a signature that has no signatory", func() {
+		It("should return a recovery error", func() {
+			r := rand.New(rand.NewSource(0))
+			hash := id.Hash{1}
+			signatory := id.NewPrivKey().Signatory()
+			numRecoveryErrors := 0
+			for i := 0; i < 20; i++ {
+				// About half of all R values are not the X coordinate of a
+				// point on the curve, so a Signatory cannot be recovered.
+				sig := id.Signature{}
+				r.Read(sig[:64])
+				sig[0] &= 0x7f
+				sig[32] &= 0x7f
+				sig[64] = byte(r.Intn(2))
+
+				err := id.Verify(&hash, &sig, &signatory)
+				Expect(err).To(HaveOccurred())
+				errRecoveringSignatory := id.ErrRecoveringSignatory{}
+				if errors.As(err, &errRecoveringSignatory) {
+					Expect(errRecoveringSignatory.Signature).To(Equal(sig))
+					Expect(errors.Unwrap(err)).To(HaveOccurred())
+					numRecoveryErrors++
+					continue
+				}
+				Expect(errors.As(err, &id.ErrSignatoryMismatch{})).To(BeTrue())
+			}
+			Expect(numRecoveryErrors).To(BeNumerically(">", 0))
+		})
+	})
+})